@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jimmykarily/quizmaker/internal/models"
+)
+
+// runAnalytics implements the "analytics" subcommand. It prints how every
+// question of the pool performed (see models.QuestionAnalytics), the hardest
+// first. It returns the exit code.
+func runAnalytics(args []string) int {
+	opts := models.AnalyticsOptions{}
+
+	fs := flag.NewFlagSet("analytics", flag.ExitOnError)
+	registerDatabaseFlags(fs)
+	fs.IntVar(&opts.MinSamples, "min-samples", models.DefaultAnalyticsMinSamples, "How many answers a question needs before it can be flagged")
+	fs.IntVar(&opts.DifficultyTolerance, "difficulty-tolerance", models.DefaultAnalyticsDifficultyTolerance, "How far the observed difficulty can be from the declared one before a question is flagged")
+	fs.Parse(args)
+
+	db, err := openDatabase(false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid settings: %s\n", err.Error())
+		return 1
+	}
+
+	stats, err := models.QuestionAnalytics(db, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Analytics failed: %s\n", err.Error())
+		return 1
+	}
+
+	flagged := 0
+	fmt.Printf("%-20s %6s %6s %6s %8s %8s %8s  %s\n", "ID", "DECL", "OBS", "SCORED", "CORRECT", "EXPIRED", "AVG TIME", "FLAG")
+	for _, s := range stats {
+		fmt.Printf("%-20s %6d %6d %6d %7.1f%% %7.1f%% %7.1fs  %s\n", s.PoolID, s.DeclaredDifficulty, s.ObservedDifficulty,
+			s.Scored, s.PercentCorrect, s.ExpiryRate, s.AverageAnswerTime.Seconds(), s.Outlier)
+		fmt.Printf("    %s\n", s.Text)
+		options := []string{}
+		for _, o := range s.Options {
+			right := ""
+			if o.Right {
+				right = " (right)"
+			}
+			options = append(options, fmt.Sprintf("%s%s: %d", o.Text, right, o.Count))
+		}
+		fmt.Printf("    %s\n", strings.Join(options, ", "))
+		if s.Outlier != "" {
+			flagged++
+		}
+	}
+	fmt.Printf("\n%d question(s), %d flagged for re-levelling\n", len(stats), flagged)
+
+	return 0
+}
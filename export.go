@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jimmykarily/quizmaker/internal/models"
+)
+
+// runExport implements the "export" subcommand. It writes the sessions and
+// their answers as CSV or JSON (see models.ExportSessions). It returns the
+// exit code.
+func runExport(args []string) int {
+	var format, from, to, state, output string
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	registerDatabaseFlags(fs)
+	fs.StringVar(&format, "format", "csv", "The output format, csv or json")
+	fs.StringVar(&from, "from", "", "Only export sessions started on or after this date (2006-01-02 or RFC3339)")
+	fs.StringVar(&to, "to", "", "Only export sessions started before this time, or on this date (2006-01-02 or RFC3339)")
+	fs.StringVar(&state, "state", models.ExportStateAll, "Only export sessions in this state: all, complete or in-progress")
+	fs.StringVar(&output, "output", "", "The file to write to (default: standard output)")
+	fs.Parse(args)
+
+	write, err := exportWriter(format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid settings: %s\n", err.Error())
+		return 1
+	}
+
+	filter, err := models.ParseExportFilter(from, to, state)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid settings: %s\n", err.Error())
+		return 1
+	}
+
+	db, err := openDatabase(false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid settings: %s\n", err.Error())
+		return 1
+	}
+
+	sessions, err := models.ExportSessions(db, filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Export failed: %s\n", err.Error())
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Export failed: %s\n", err.Error())
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := write(w, sessions); err != nil {
+		fmt.Fprintf(os.Stderr, "Export failed: %s\n", err.Error())
+		return 1
+	}
+	if output != "" {
+		fmt.Fprintf(os.Stderr, "Exported %d session(s) to %s\n", len(sessions), output)
+	}
+
+	return 0
+}
+
+func exportWriter(format string) (func(io.Writer, []models.ExportedSession) error, error) {
+	switch format {
+	case "csv":
+		return models.WriteExportCSV, nil
+	case "json":
+		return models.WriteExportJSON, nil
+	default:
+		return nil, fmt.Errorf("invalid format %q, expected csv or json", format)
+	}
+}
@@ -0,0 +1,424 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/securecookie"
+	"github.com/jimmykarily/quizmaker/internal/models"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ADMIN_REALM = "quizmaker admin"
+
+	// ADMIN_CSRF_FIELD is the form field (or the header, for scripts) with the
+	// token of the forms of the admin pages (see adminCSRFToken)
+	ADMIN_CSRF_FIELD        = "csrf_token"
+	ADMIN_CSRF_HEADER       = "X-CSRF-Token"
+	ADMIN_CSRF_LIFETIME_SEC = 12 * 3600
+)
+
+type (
+	AdminController struct{}
+
+	// adminSessionRow is a session on the admin session list
+	adminSessionRow struct {
+		models.Session
+		Answered int
+		ShowPath string
+	}
+
+	// regradeResponse describes the effect of regrading (or voiding)
+	regradeResponse struct {
+		UpdatedQuestions int                `json:"updatedQuestions"`
+		UpdatedSessions  int                `json:"updatedSessions"`
+		Before           []leaderboardEntry `json:"before"`
+		After            []leaderboardEntry `json:"after"`
+	}
+
+	leaderboardEntry struct {
+		Nickname string `json:"nickname"`
+		Email    string `json:"email"`
+		Score    int    `json:"score"`
+		Points   int    `json:"points"`
+	}
+)
+
+// List shows all the sessions with their full emails
+func (c *AdminController) List(gctx *gin.Context) {
+	sessions := []models.Session{}
+	err := Settings.DB.Preload("Questions").Order("created_at DESC").Find(&sessions).Error
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	ranked, err := models.CompletedLeaderboard(Settings.DB)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+	ranks := map[uint]int{}
+	for _, s := range ranked {
+		ranks[s.ID] = s.Rank
+	}
+
+	rows := []adminSessionRow{}
+	for _, s := range sessions {
+		s.Rank = ranks[s.ID]
+		showPath, err := GetRoutePath("AdminSessionShow", map[string]string{"id": strconv.Itoa(int(s.ID))})
+		if renderError(gctx, err, http.StatusInternalServerError) {
+			return
+		}
+		answered := 0
+		for _, q := range s.Questions {
+			if q.Answered() {
+				answered++
+			}
+		}
+		rows = append(rows, adminSessionRow{Session: s, Answered: answered, ShowPath: showPath})
+	}
+
+	questionsPath, err := GetRoutePath("AdminQuestionList", nil)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	analyticsPath, err := GetRoutePath("AdminAnalytics", nil)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+	exportCSVPath, err := GetRoutePath("AdminExportCSV", nil)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+	exportJSONPath, err := GetRoutePath("AdminExportJSON", nil)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	viewData := struct {
+		Sessions       []adminSessionRow
+		QuestionsPath  string
+		AnalyticsPath  string
+		ExportCSVPath  string
+		ExportJSONPath string
+	}{
+		Sessions:       rows,
+		QuestionsPath:  questionsPath,
+		AnalyticsPath:  analyticsPath,
+		ExportCSVPath:  exportCSVPath,
+		ExportJSONPath: exportJSONPath,
+	}
+
+	RenderPage(gctx, []string{"main_layout", path.Join("admin", "sessions")}, viewData)
+}
+
+// Show shows a session with all its answers
+func (c *AdminController) Show(gctx *gin.Context) {
+	session, ok := adminSession(gctx)
+	if !ok {
+		return
+	}
+	sort.Slice(session.Questions, func(i, j int) bool {
+		return session.Questions[i].Index < session.Questions[j].Index
+	})
+
+	params := map[string]string{"id": gctx.Param("id")}
+	paths := map[string]string{}
+	for _, name := range []string{"AdminSessionList", "AdminSessionReset", "AdminSessionDelete", "AdminSessionDisqualify"} {
+		p, err := GetRoutePath(name, params)
+		if renderError(gctx, err, http.StatusInternalServerError) {
+			return
+		}
+		paths[name] = p
+	}
+
+	csrfToken, err := adminCSRFToken()
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	viewData := struct {
+		Session   models.Session
+		Paths     map[string]string
+		CSRFToken string
+	}{
+		Session:   session,
+		Paths:     paths,
+		CSRFToken: csrfToken,
+	}
+
+	RenderPage(gctx, []string{"main_layout", path.Join("admin", "session")}, viewData)
+}
+
+// Reset deletes the answers of the session so that the participant can start
+// over (see models.Session.Reset)
+func (c *AdminController) Reset(gctx *gin.Context) {
+	c.sessionAction(gctx, "AdminSessionShow", func(session *models.Session) (string, error) {
+		return fmt.Sprintf("The session of %s was reset.", session.Email), session.Reset(Settings.DB)
+	})
+}
+
+// Delete deletes the session and its answers, the email can be used again
+func (c *AdminController) Delete(gctx *gin.Context) {
+	c.sessionAction(gctx, "AdminSessionList", func(session *models.Session) (string, error) {
+		return fmt.Sprintf("The session of %s was deleted.", session.Email), session.Delete(Settings.DB)
+	})
+}
+
+// Disqualify hides the session from the leaderboard. Posting
+// "disqualified=false" shows it again.
+func (c *AdminController) Disqualify(gctx *gin.Context) {
+	disqualified := gctx.PostForm("disqualified") != "false"
+	c.sessionAction(gctx, "AdminSessionShow", func(session *models.Session) (string, error) {
+		message := fmt.Sprintf("%s was disqualified.", session.Email)
+		if !disqualified {
+			message = fmt.Sprintf("%s is back on the leaderboard.", session.Email)
+		}
+		return message, session.SetDisqualified(Settings.DB, disqualified)
+	})
+}
+
+// sessionAction runs an action on the session of the "id" path parameter,
+// updates the live leaderboards and redirects to the given route with the
+// message of the action as a flash.
+func (c *AdminController) sessionAction(gctx *gin.Context, routeName string, action func(*models.Session) (string, error)) {
+	session, ok := adminSession(gctx)
+	if !ok {
+		return
+	}
+
+	message, err := action(&session)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+	NotifyLeaderboardChanged()
+
+	redirectURL, err := GetFullURL(gctx.Request, routeName, map[string]string{"id": gctx.Param("id")})
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	addFlash(gctx, FlashInfo, message)
+	gctx.Redirect(http.StatusFound, redirectURL)
+}
+
+// Questions shows the loaded question pool
+func (c *AdminController) Questions(gctx *gin.Context) {
+	pool, err := currentQuestionPool()
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	sessionsPath, err := GetRoutePath("AdminSessionList", nil)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	viewData := struct {
+		Pool         models.QuestionPool
+		SessionsPath string
+	}{
+		Pool:         pool,
+		SessionsPath: sessionsPath,
+	}
+
+	RenderPage(gctx, []string{"main_layout", path.Join("admin", "questions")}, viewData)
+}
+
+// Analytics shows how every question performed (see models.QuestionAnalytics)
+func (c *AdminController) Analytics(gctx *gin.Context) {
+	stats, err := models.QuestionAnalytics(Settings.DB, models.AnalyticsOptions{
+		MinSamples:          models.DefaultAnalyticsMinSamples,
+		DifficultyTolerance: models.DefaultAnalyticsDifficultyTolerance,
+	})
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	sessionsPath, err := GetRoutePath("AdminSessionList", nil)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	viewData := struct {
+		Stats        []models.QuestionStats
+		SessionsPath string
+	}{
+		Stats:        stats,
+		SessionsPath: sessionsPath,
+	}
+
+	RenderPage(gctx, []string{"main_layout", path.Join("admin", "analytics")}, viewData)
+}
+
+// ExportCSV downloads the sessions and their answers as CSV. The "from",
+// "to" and "state" query parameters filter the sessions like the flags of the
+// "export" command.
+func (c *AdminController) ExportCSV(gctx *gin.Context) {
+	c.export(gctx, "text/csv", "csv", models.WriteExportCSV)
+}
+
+// ExportJSON is like ExportCSV but downloads JSON
+func (c *AdminController) ExportJSON(gctx *gin.Context) {
+	c.export(gctx, "application/json", "json", models.WriteExportJSON)
+}
+
+func (c *AdminController) export(gctx *gin.Context, contentType, extension string, write func(io.Writer, []models.ExportedSession) error) {
+	filter, err := models.ParseExportFilter(gctx.Query("from"), gctx.Query("to"), gctx.Query("state"))
+	if renderError(gctx, err, http.StatusBadRequest) {
+		return
+	}
+
+	sessions, err := models.ExportSessions(Settings.DB, filter)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	gctx.Header("Content-Type", contentType+"; charset=utf-8")
+	gctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="quizmaker-results.%s"`, extension))
+	if err := write(gctx.Writer, sessions); err != nil && Settings.ErrorLogger != nil {
+		Settings.ErrorLogger.Printf("exporting results: %s\n", err.Error())
+	}
+}
+
+func (c *AdminController) Regrade(gctx *gin.Context) {
+	pool, err := currentQuestionPool()
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	result, err := models.Regrade(Settings.DB, pool)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	gctx.JSON(http.StatusOK, newRegradeResponse(result))
+}
+
+// Void voids the question with the pool id in the path for all sessions.
+// Posting "voided=false" restores it.
+func (c *AdminController) Void(gctx *gin.Context) {
+	voided := gctx.PostForm("voided") != "false"
+	result, err := models.SetVoided(Settings.DB, gctx.Param("id"), voided)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	gctx.JSON(http.StatusOK, newRegradeResponse(result))
+}
+
+// requireAdmin is a middleware that stops the request unless it comes from an
+// admin (see checkAdminToken). Browsers prompt for the token (as the password
+// of any user) because of the WWW-Authenticate header.
+// Browsers send the basic auth credentials on their own, also when another
+// site makes them post to the admin endpoints. Such requests need the token
+// of adminCSRFToken too.
+func requireAdmin(gctx *gin.Context) {
+	if err := checkAdminToken(gctx); err != nil {
+		gctx.Header("WWW-Authenticate", `Basic realm="`+ADMIN_REALM+`"`)
+		renderError(gctx, err, http.StatusUnauthorized)
+		gctx.Abort()
+		return
+	}
+
+	if gctx.Request.Method == http.MethodGet || gctx.Request.Method == http.MethodHead {
+		return
+	}
+	if strings.HasPrefix(gctx.GetHeader("Authorization"), "Bearer ") {
+		return // not sent by browsers on their own
+	}
+	token := gctx.GetHeader(ADMIN_CSRF_HEADER)
+	if token == "" {
+		token = gctx.PostForm(ADMIN_CSRF_FIELD)
+	}
+	if err := checkAdminCSRFToken(token); err != nil {
+		renderError(gctx, err, http.StatusForbidden)
+		gctx.Abort()
+	}
+}
+
+// adminCSRFToken returns a signed token for the forms of the admin pages. It
+// expires after ADMIN_CSRF_LIFETIME_SEC.
+func adminCSRFToken() (string, error) {
+	sc := securecookie.New([]byte(Settings.CookieSecret), nil).MaxAge(ADMIN_CSRF_LIFETIME_SEC)
+	token, err := sc.Encode(ADMIN_CSRF_FIELD, time.Now().Unix())
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the CSRF token: %w", err)
+	}
+
+	return token, nil
+}
+
+// checkAdminCSRFToken returns an error unless the token was created by
+// adminCSRFToken and hasn't expired
+func checkAdminCSRFToken(token string) error {
+	if token == "" {
+		return errInvalidCSRFToken
+	}
+	var issuedAt int64
+	sc := securecookie.New([]byte(Settings.CookieSecret), nil).MaxAge(ADMIN_CSRF_LIFETIME_SEC)
+	if err := sc.Decode(ADMIN_CSRF_FIELD, token, &issuedAt); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidCSRFToken, err)
+	}
+
+	return nil
+}
+
+// adminSession loads the session of the "id" path parameter, with its
+// questions, or renders an error
+func adminSession(gctx *gin.Context) (models.Session, bool) {
+	var session models.Session
+	err := Settings.DB.Preload(clause.Associations).First(&session, "id = ?", gctx.Param("id")).Error
+	if renderError(gctx, err, http.StatusNotFound) {
+		return session, false
+	}
+
+	return session, true
+}
+
+// checkAdminToken returns an error unless the request has an
+// "Authorization: Bearer <token>" header, or basic auth credentials with a
+// password, matching Settings.AdminToken.
+// The admin endpoints are disabled when no token is configured.
+func checkAdminToken(ctx *gin.Context) error {
+	if Settings.AdminToken == "" {
+		return errAdminDisabled
+	}
+
+	token, found := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if !found {
+		_, token, found = ctx.Request.BasicAuth()
+	}
+	if !found || subtle.ConstantTimeCompare([]byte(token), []byte(Settings.AdminToken)) != 1 {
+		return errNotAdmin
+	}
+
+	return nil
+}
+
+func newRegradeResponse(result models.RegradeResult) regradeResponse {
+	return regradeResponse{
+		UpdatedQuestions: result.UpdatedQuestions,
+		UpdatedSessions:  result.UpdatedSessions,
+		Before:           leaderboardEntries(result.Before),
+		After:            leaderboardEntries(result.After),
+	}
+}
+
+func leaderboardEntries(sessions []models.Session) []leaderboardEntry {
+	result := []leaderboardEntry{}
+	for _, s := range sessions {
+		result = append(result, leaderboardEntry{Nickname: s.Nickname, Email: s.Email, Score: s.Score, Points: s.Points})
+	}
+
+	return result
+}
@@ -0,0 +1,222 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jimmykarily/quizmaker/internal/controllers"
+	"github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AdminController test", func() {
+	var router *gin.Engine
+	var w *httptest.ResponseRecorder
+	var req *http.Request
+
+	BeforeEach(func() {
+		router = gin.Default()
+		controllers.SetupRoutes(router, controllers.GetRoutes())
+
+		w = httptest.NewRecorder()
+
+		controllers.Settings.QuestionPoolFile =
+			filepath.Join(currentDir, "tests/assets/question_pool.yaml")
+		controllers.Settings.AdminToken = "secret-token"
+
+		path, err := controllers.GetRoutePath("AdminRegrade", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req, err = http.NewRequest("POST", path, nil)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		controllers.Settings.AdminToken = ""
+	})
+
+	Describe("#Regrade", func() {
+		It("requires the admin token", func() {
+			router.ServeHTTP(w, req)
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+
+			w = httptest.NewRecorder()
+			req.Header.Set("Authorization", "Bearer wrong-token")
+			router.ServeHTTP(w, req)
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("is disabled when no admin token is configured", func() {
+			controllers.Settings.AdminToken = ""
+			req.Header.Set("Authorization", "Bearer ")
+			router.ServeHTTP(w, req)
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("regrades the sessions", func() {
+			req.Header.Set("Authorization", "Bearer secret-token")
+			router.ServeHTTP(w, req)
+			Expect(w.Code).To(Equal(http.StatusOK), w.Body.String())
+
+			var body map[string]interface{}
+			Expect(json.Unmarshal(w.Body.Bytes(), &body)).To(Succeed())
+			Expect(body).To(HaveKeyWithValue("updatedQuestions", BeNumerically("==", 0)))
+		})
+	})
+
+	Describe("the admin pages", func() {
+		var session models.Session
+
+		adminRequestWithForm := func(verb, routeName string, id uint, form url.Values) *httptest.ResponseRecorder {
+			path, err := controllers.GetRoutePath(routeName, map[string]string{"id": strconv.Itoa(int(id))})
+			Expect(err).ToNot(HaveOccurred())
+			req, err := http.NewRequest(verb, path, strings.NewReader(form.Encode()))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth("admin", "secret-token")
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		// adminRequest posts the forms of the admin pages with their CSRF token
+		adminRequest := func(verb, routeName string, id uint) *httptest.ResponseRecorder {
+			form := url.Values{}
+			if verb == "POST" {
+				w := adminRequestWithForm("GET", "AdminSessionShow", id, nil)
+				token := regexp.MustCompile(`name="csrf_token" value="([^"]+)"`).FindStringSubmatch(w.Body.String())
+				Expect(token).To(HaveLen(2), w.Body.String())
+				form.Set("csrf_token", html.UnescapeString(token[1]))
+			}
+
+			return adminRequestWithForm(verb, routeName, id, form)
+		}
+
+		BeforeEach(func() {
+			var err error
+			session, err = models.NewSession(controllers.Settings.DB, "alice@example.com", "alice")
+			Expect(err).ToNot(HaveOccurred())
+			quiz := models.Quiz{Questions: []models.Question{
+				{Text: "What is Kairos?", Answers: models.Answers{"An OS", "A fruit"}, RightAnswer: 1, UserAnswer: 2,
+					StartedAt: time.Now(), AnsweredAt: time.Now(), AllowedSeconds: 30},
+			}}
+			Expect(quiz.PersistForSessionEmail(controllers.Settings.DB, session.Email)).To(Succeed())
+			session.Complete = true
+			Expect(controllers.Settings.DB.Omit("Questions").Save(&session).Error).To(Succeed())
+		})
+
+		It("asks browsers for the token", func() {
+			path, err := controllers.GetRoutePath("AdminSessionList", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req, err := http.NewRequest("GET", path, nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.SetBasicAuth("admin", "wrong-token")
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+			Expect(w.Header().Get("WWW-Authenticate")).To(ContainSubstring("Basic"))
+		})
+
+		It("lists the sessions with their full emails", func() {
+			w := adminRequest("GET", "AdminSessionList", 0)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(ContainSubstring("alice@example.com"))
+		})
+
+		It("escapes what the participants typed", func() {
+			Expect(controllers.Settings.DB.Model(&session).Update("nickname", "<script>alert(1)</script>").Error).To(Succeed())
+
+			for _, w := range []*httptest.ResponseRecorder{
+				adminRequest("GET", "AdminSessionList", 0),
+				adminRequest("GET", "AdminSessionShow", session.ID),
+			} {
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Body.String()).To(ContainSubstring("&lt;script&gt;alert(1)&lt;/script&gt;"))
+				Expect(w.Body.String()).ToNot(ContainSubstring("<script>alert(1)"))
+			}
+		})
+
+		It("rejects the forms without a valid CSRF token", func() {
+			for _, form := range []url.Values{{}, {"csrf_token": {"forged"}}} {
+				w := adminRequestWithForm("POST", "AdminSessionDelete", session.ID, form)
+				Expect(w.Code).To(Equal(http.StatusForbidden))
+			}
+			_, err := models.SessionForEmail(controllers.Settings.DB, session.Email)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("shows the answers of a session", func() {
+			w := adminRequest("GET", "AdminSessionShow", session.ID)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(ContainSubstring("What is Kairos?"))
+			Expect(w.Body.String()).To(ContainSubstring("A fruit"))
+		})
+
+		It("disqualifies a session", func() {
+			w := adminRequest("POST", "AdminSessionDisqualify", session.ID)
+			Expect(w.Code).To(Equal(http.StatusFound))
+
+			leaderboard, err := models.CompletedLeaderboard(controllers.Settings.DB)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(leaderboard).To(BeEmpty())
+		})
+
+		It("resets a session", func() {
+			w := adminRequest("POST", "AdminSessionReset", session.ID)
+			Expect(w.Code).To(Equal(http.StatusFound))
+
+			reloaded, err := models.SessionForEmail(controllers.Settings.DB, session.Email)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reloaded.Complete).To(BeFalse())
+		})
+
+		It("deletes a session", func() {
+			w := adminRequest("POST", "AdminSessionDelete", session.ID)
+			Expect(w.Code).To(Equal(http.StatusFound))
+
+			_, err := models.SessionForEmail(controllers.Settings.DB, session.Email)
+			Expect(err).To(HaveOccurred())
+
+			w = adminRequest("GET", "AdminSessionShow", session.ID)
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("exports the results", func() {
+			w := adminRequest("GET", "AdminExportCSV", 0)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Header().Get("Content-Disposition")).To(ContainSubstring("quizmaker-results.csv"))
+			Expect(w.Body.String()).To(ContainSubstring("alice@example.com,alice,"))
+			Expect(w.Body.String()).To(ContainSubstring("What is Kairos?"))
+
+			w = adminRequest("GET", "AdminExportJSON", 0)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			var body []map[string]interface{}
+			Expect(json.Unmarshal(w.Body.Bytes(), &body)).To(Succeed())
+			Expect(body).To(HaveLen(1))
+			Expect(body[0]).To(HaveKeyWithValue("email", "alice@example.com"))
+		})
+
+		It("shows the question analytics", func() {
+			w := adminRequest("GET", "AdminAnalytics", 0)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(ContainSubstring("What is Kairos?"))
+			Expect(w.Body.String()).To(ContainSubstring("0.0%"))
+		})
+
+		It("shows the question pool", func() {
+			w := adminRequest("GET", "AdminQuestionList", 0)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(ContainSubstring("Question pool"))
+		})
+	})
+})
@@ -0,0 +1,297 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jimmykarily/quizmaker/internal/models"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	// APIController serves the quiz as JSON under /api/v1, for clients other
+	// than the browser (e.g. a kiosk app). It uses the same session token as
+	// the cookie of the HTML routes, sent as an "Authorization: Bearer" header.
+	APIController struct{}
+
+	apiSessionRequest struct {
+		Email    string `json:"email" form:"email"`
+		Nickname string `json:"nickname,omitempty" form:"nickname"`
+	}
+
+	// apiAnswerRequest holds one of the answer kinds, depending on the type of
+	// the question
+	apiAnswerRequest struct {
+		Answer  *int   `json:"answer,omitempty"`  // single choice questions
+		Answers []int  `json:"answers,omitempty"` // multiple-choice questions
+		Text    string `json:"text,omitempty"`    // free input questions
+	}
+
+	apiSession struct {
+		Email     string    `json:"email"`
+		Nickname  string    `json:"nickname"`
+		Score     int       `json:"score"`
+		Points    int       `json:"points"`
+		Complete  bool      `json:"complete"`
+		Rank      int       `json:"rank,omitempty"`
+		Answered  int       `json:"answered"`
+		Total     int       `json:"total"`
+		StartedAt time.Time `json:"startedAt"`
+	}
+
+	// apiQuestion is a question as it's asked, without the right answers
+	apiQuestion struct {
+		ID             uint      `json:"id"`
+		Index          int       `json:"index"`
+		Total          int       `json:"total"`
+		Text           string    `json:"text"`
+		Type           string    `json:"type"`
+		Answers        []string  `json:"answers,omitempty"`
+		MultipleChoice bool      `json:"multipleChoice"`
+		FreeInput      bool      `json:"freeInput"`
+		StartedAt      time.Time `json:"startedAt"`
+		Deadline       time.Time `json:"deadline"`
+	}
+
+	apiSessionCreated struct {
+		Token     string     `json:"token"`
+		ExpiresAt time.Time  `json:"expiresAt"`
+		Session   apiSession `json:"session"`
+	}
+
+	apiSessionResponse struct {
+		Session apiSession  `json:"session"`
+		Results []apiResult `json:"results"` // empty until the quiz is complete
+	}
+
+	apiQuestionResponse struct {
+		Question apiQuestion `json:"question"`
+	}
+
+	apiAnswerResponse struct {
+		Session apiSession `json:"session"`
+	}
+
+	// apiResult is a question of a complete quiz, with the right answers
+	apiResult struct {
+		Index       int                   `json:"index"`
+		Text        string                `json:"text"`
+		Answer      []string              `json:"answer"`
+		RightAnswer []string              `json:"rightAnswer"`
+		Correct     bool                  `json:"correct"`
+		Status      models.QuestionStatus `json:"status"`
+		Source      string                `json:"source,omitempty"`
+	}
+)
+
+// CreateSession starts a quiz for a new email, like QuizController.Create,
+// and returns the token of the session.
+func (c *APIController) CreateSession(gctx *gin.Context) {
+	var request apiSessionRequest
+	if renderError(gctx, gctx.ShouldBind(&request), http.StatusBadRequest) {
+		return
+	}
+	if !models.ValidEmail(request.Email) {
+		renderError(gctx, errInvalidEmail, http.StatusUnprocessableEntity)
+		return
+	}
+	if _, err := models.SessionForEmail(Settings.DB, request.Email); err == nil {
+		renderError(gctx, errEmailUsed, http.StatusConflict)
+		return
+	}
+
+	session, err := models.NewSession(Settings.DB, request.Email, request.Nickname)
+	if errors.Is(err, models.ErrEmailUsed) { // created by a concurrent request
+		renderError(gctx, errEmailUsed, http.StatusConflict)
+		return
+	}
+	if errors.Is(err, models.ErrNicknameTooLong) {
+		renderError(gctx, err, http.StatusUnprocessableEntity)
+		return
+	}
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+	if renderError(gctx, startQuiz(session), http.StatusInternalServerError) {
+		return
+	}
+	if renderError(gctx, Settings.DB.Preload(clause.Associations).Find(&session).Error, http.StatusInternalServerError) {
+		return
+	}
+
+	token, err := CreateCookie(session.Email, gctx.Request.UserAgent())
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	gctx.JSON(http.StatusCreated, apiSessionCreated{
+		Token:     token.Value,
+		ExpiresAt: token.Expires,
+		Session:   newAPISession(session),
+	})
+}
+
+// ShowSession returns the session of the token and, once the quiz is
+// complete, the results with the right answers.
+func (c *APIController) ShowSession(gctx *gin.Context) {
+	session, err := currentSession(gctx)
+	if renderError(gctx, err, http.StatusUnauthorized) {
+		return
+	}
+	if renderError(gctx, Settings.DB.Preload(clause.Associations).Find(&session).Error, http.StatusInternalServerError) {
+		return
+	}
+
+	result := newAPISession(session)
+	results := []apiResult{}
+	if session.Complete {
+		leaderboard, err := models.CompletedLeaderboard(Settings.DB)
+		if renderError(gctx, err, http.StatusInternalServerError) {
+			return
+		}
+		for _, s := range leaderboard {
+			if s.ID == session.ID {
+				result.Rank = s.Rank
+			}
+		}
+
+		sort.Slice(session.Questions, func(i, j int) bool {
+			return session.Questions[i].Index < session.Questions[j].Index
+		})
+		for _, q := range session.Questions {
+			results = append(results, apiResult{
+				Index:       q.Index,
+				Text:        q.Text,
+				Answer:      q.UserAnswerTexts(),
+				RightAnswer: q.RightAnswerTexts(),
+				Correct:     q.Answered() && q.Correct(),
+				Status:      q.Status(),
+				Source:      q.Source,
+			})
+		}
+	}
+
+	gctx.JSON(http.StatusOK, apiSessionResponse{Session: result, Results: results})
+}
+
+// ShowQuestion returns the current question of the session, like
+// QuizController.Show. The time to answer starts running with the first call.
+func (c *APIController) ShowQuestion(gctx *gin.Context) {
+	session, err := currentSession(gctx)
+	if renderError(gctx, err, http.StatusUnauthorized) {
+		return
+	}
+
+	question, err := restartOrCurrentQuestion(&session)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+	if question.ID == 0 {
+		renderError(gctx, errQuizComplete, http.StatusNotFound)
+		return
+	}
+
+	gctx.JSON(http.StatusOK, apiQuestionResponse{Question: newAPIQuestion(question, len(session.Questions))})
+}
+
+// Answer submits the answer to a question of the session
+func (c *APIController) Answer(gctx *gin.Context) {
+	session, err := currentSession(gctx)
+	if renderError(gctx, err, http.StatusUnauthorized) {
+		return
+	}
+
+	var request apiAnswerRequest
+	if renderError(gctx, gctx.ShouldBindJSON(&request), http.StatusBadRequest) {
+		return
+	}
+
+	var question models.Question
+	err = Settings.DB.First(&question, "id = ?", gctx.Param("id")).Error
+	if renderError(gctx, err, http.StatusNotFound) {
+		return
+	}
+	if question.SessionID != session.ID {
+		renderError(gctx, errNotYourQuestion, http.StatusForbidden)
+		return
+	}
+
+	if code, err := submitAnswer(&session, &question, request.values(), time.Now()); err != nil {
+		renderError(gctx, err, code)
+		return
+	}
+
+	gctx.JSON(http.StatusOK, apiAnswerResponse{Session: newAPISession(session)})
+}
+
+// Leaderboard returns the leaderboard with obfuscated emails, like the one
+// pushed to the live leaderboards
+func (c *APIController) Leaderboard(gctx *gin.Context) {
+	event, err := currentLeaderboardEvent()
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	gctx.JSON(http.StatusOK, event)
+}
+
+// values returns the answer as the form values of the HTML route (see
+// setSubmittedAnswer)
+func (r apiAnswerRequest) values() []string {
+	switch {
+	case r.Text != "":
+		return []string{r.Text}
+	case len(r.Answers) > 0:
+		values := []string{}
+		for _, a := range r.Answers {
+			values = append(values, strconv.Itoa(a))
+		}
+		return values
+	case r.Answer != nil:
+		return []string{strconv.Itoa(*r.Answer)}
+	default:
+		return nil
+	}
+}
+
+func newAPISession(s models.Session) apiSession {
+	result := apiSession{
+		Email:     s.Email,
+		Nickname:  s.Nickname,
+		Score:     s.Score,
+		Points:    s.Points,
+		Complete:  s.Complete,
+		Total:     len(s.Questions),
+		StartedAt: s.CreatedAt,
+	}
+	for _, q := range s.Questions {
+		if q.Answered() {
+			result.Answered++
+		}
+	}
+
+	return result
+}
+
+func newAPIQuestion(q models.Question, total int) apiQuestion {
+	result := apiQuestion{
+		ID:             q.ID,
+		Index:          q.Index,
+		Total:          total,
+		Text:           q.Text,
+		Type:           string(q.Type),
+		MultipleChoice: q.IsMultipleChoice(),
+		FreeInput:      q.IsFreeInput(),
+		StartedAt:      q.StartedAt,
+		Deadline:       q.Deadline(),
+	}
+	if !q.IsFreeInput() {
+		result.Answers = q.Answers
+	}
+
+	return result
+}
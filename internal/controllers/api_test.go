@@ -0,0 +1,146 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jimmykarily/quizmaker/internal/controllers"
+	"github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("APIController test", func() {
+	var router *gin.Engine
+
+	// request performs a JSON request and decodes the JSON response
+	request := func(verb, routeName string, params map[string]string, token string, body interface{}) (int, map[string]interface{}) {
+		path, err := controllers.GetRoutePath(routeName, params)
+		Expect(err).ToNot(HaveOccurred())
+		encoded, err := json.Marshal(body)
+		Expect(err).ToNot(HaveOccurred())
+		req, err := http.NewRequest(verb, path, bytes.NewReader(encoded))
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		Expect(w.Header().Get("Content-Type")).To(ContainSubstring("application/json"))
+		result := map[string]interface{}{}
+		Expect(json.Unmarshal(w.Body.Bytes(), &result)).To(Succeed(), w.Body.String())
+
+		return w.Code, result
+	}
+
+	createSession := func(email string) string {
+		code, body := request("POST", "APISessionCreate", nil, "", map[string]string{"email": email, "nickname": "alice"})
+		Expect(code).To(Equal(http.StatusCreated), fmt.Sprint(body))
+		Expect(body["session"]).To(HaveKeyWithValue("total", BeNumerically("==", 2)))
+
+		return body["token"].(string)
+	}
+
+	BeforeEach(func() {
+		router = gin.Default()
+		controllers.SetupRoutes(router, controllers.GetRoutes())
+
+		controllers.Settings.QuestionPoolFile =
+			filepath.Join(currentDir, "tests/assets/question_pool.yaml")
+		controllers.Settings.QuizOverrides = models.QuizConfig{TotalQuestions: 2}
+	})
+
+	AfterEach(func() {
+		controllers.Settings.QuizOverrides = models.QuizConfig{}
+	})
+
+	It("runs a quiz", func() {
+		token := createSession("alice@example.com")
+
+		for i := 1; i <= 2; i++ {
+			code, body := request("GET", "APIQuestionShow", nil, token, nil)
+			Expect(code).To(Equal(http.StatusOK))
+			question := body["question"].(map[string]interface{})
+			Expect(question).To(HaveKeyWithValue("index", BeNumerically("==", i)))
+			Expect(question).To(HaveKey("answers"))
+			Expect(question).ToNot(HaveKey("rightAnswer"))
+
+			var persisted models.Question
+			Expect(controllers.Settings.DB.First(&persisted, question["id"]).Error).ToNot(HaveOccurred())
+			id := fmt.Sprint(question["id"])
+			code, body = request("POST", "APIQuestionAnswer", map[string]string{"id": id}, token,
+				map[string]int{"answer": persisted.CorrectAnswers()[0]})
+			Expect(code).To(Equal(http.StatusOK), fmt.Sprint(body))
+			Expect(body["session"]).To(HaveKeyWithValue("answered", BeNumerically("==", i)))
+
+			code, body = request("POST", "APIQuestionAnswer", map[string]string{"id": id}, token, map[string]int{"answer": 1})
+			Expect(code).To(Equal(http.StatusConflict))
+			Expect(body["error"]).To(HaveKeyWithValue("message", "You have already answered this question."))
+		}
+
+		code, body := request("GET", "APIQuestionShow", nil, token, nil)
+		Expect(code).To(Equal(http.StatusNotFound))
+		Expect(body["error"]).To(HaveKeyWithValue("message", "The quiz is complete, there are no more questions."))
+
+		code, body = request("GET", "APISessionShow", nil, token, nil)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body["session"]).To(HaveKeyWithValue("complete", true))
+		Expect(body["session"]).To(HaveKeyWithValue("score", BeNumerically("==", 100)))
+		Expect(body["session"]).To(HaveKeyWithValue("rank", BeNumerically("==", 1)))
+		Expect(body["results"]).To(HaveLen(2))
+
+		code, body = request("GET", "APILeaderboard", nil, "", nil)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body["completed"]).To(ConsistOf(HaveKeyWithValue("email", "a...e@e.....e.com")))
+	})
+
+	It("returns structured errors", func() {
+		code, body := request("POST", "APISessionCreate", nil, "", map[string]string{"email": "not an email"})
+		Expect(code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(body).To(HaveKeyWithValue("error", map[string]interface{}{
+			"status":  float64(http.StatusUnprocessableEntity),
+			"reason":  "Unprocessable Entity",
+			"message": "Please enter a valid email address.",
+		}))
+
+		createSession("alice@example.com")
+		code, body = request("POST", "APISessionCreate", nil, "", map[string]string{"email": "alice@example.com"})
+		Expect(code).To(Equal(http.StatusConflict))
+		Expect(body["error"]).To(HaveKeyWithValue("message", "This email has already been used to take the quiz."))
+
+		code, _ = request("GET", "APIQuestionShow", nil, "", nil)
+		Expect(code).To(Equal(http.StatusUnauthorized))
+		code, _ = request("GET", "APIQuestionShow", nil, "invalid-token", nil)
+		Expect(code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("doesn't accept answers to the questions of other sessions", func() {
+		alice := createSession("alice@example.com")
+		bob := createSession("bob@example.com")
+
+		_, body := request("GET", "APIQuestionShow", nil, alice, nil)
+		id := fmt.Sprint(body["question"].(map[string]interface{})["id"])
+
+		code, _ := request("POST", "APIQuestionAnswer", map[string]string{"id": id}, bob, map[string]int{"answer": 1})
+		Expect(code).To(Equal(http.StatusForbidden))
+	})
+
+	It("starts over after an admin resets the session", func() {
+		token := createSession("alice@example.com")
+		session, err := models.SessionForEmail(controllers.Settings.DB, "alice@example.com")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(session.Reset(controllers.Settings.DB)).To(Succeed())
+
+		code, body := request("GET", "APIQuestionShow", nil, token, nil)
+		Expect(code).To(Equal(http.StatusOK), fmt.Sprint(body))
+		Expect(body["question"]).To(HaveKeyWithValue("index", BeNumerically("==", 1)))
+		Expect(body["question"]).To(HaveKeyWithValue("total", BeNumerically("==", 2)))
+	})
+})
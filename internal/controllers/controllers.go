@@ -2,10 +2,12 @@ package controllers
 
 import (
 	"fmt"
+	templatepkg "html/template"
 	"io"
 	"net/http"
 	"os"
-	templatepkg "text/template"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,12 +17,25 @@ import (
 	"github.com/skip2/go-qrcode"
 )
 
+const formatContextKey = "quizmaker-format"
+
 var Settings settingspkg.Settings
 var QuizNewQRImageMemoization map[string][]byte
+var quizNewQRImageMu sync.Mutex // guards QuizNewQRImageMemoization
 
 // Render renders the given templates using the provided data and writes the result
 // to the provided ResponseWriter.
 func Render(templates []string, w http.ResponseWriter, data interface{}) {
+	render(templates, w, data, []Flash{})
+}
+
+// RenderPage is like Render but also shows the pending flash messages (see
+// addFlash) in the layout.
+func RenderPage(gctx *gin.Context, templates []string, data interface{}) {
+	render(templates, gctx.Writer, data, popFlashes(gctx))
+}
+
+func render(templates []string, w http.ResponseWriter, data interface{}, flashes []Flash) {
 	var (
 		err         error
 		tmplFile    *os.File
@@ -46,6 +61,9 @@ func Render(templates []string, w http.ResponseWriter, data interface{}) {
 			"sub": func(a, b int) int {
 				return a - b
 			},
+			"flashes": func() []Flash {
+				return flashes
+			},
 		}).Parse(string(tmplContent)))
 	}
 
@@ -63,7 +81,8 @@ func Render(templates []string, w http.ResponseWriter, data interface{}) {
 func SetupRoutes(e *gin.Engine, routes Routes) {
 	e.Static("/assets", "./assets")
 	for _, r := range routes {
-		e.Handle(r.Method, r.Path, r.Handler)
+		handlers := append([]gin.HandlerFunc{withFormat(r.Format)}, r.Middlewares...)
+		e.Handle(r.Method, r.Path, append(handlers, r.Handler)...)
 	}
 }
 
@@ -79,22 +98,37 @@ func handleError(w http.ResponseWriter, err error, code int) bool {
 	return false
 }
 
+// withFormat is a middleware that remembers the Format of the route, so that
+// errors are rendered in the same format (see renderError)
+func withFormat(format string) gin.HandlerFunc {
+	return func(gctx *gin.Context) {
+		gctx.Set(formatContextKey, format)
+	}
+}
+
+// validCookieValue decodes the session cookie or, for API clients that don't
+// keep cookies, the same value sent as an "Authorization: Bearer" token.
 func validCookieValue(ctx *gin.Context) (CookieValue, error) {
 	var result CookieValue
 
 	sc := securecookie.New([]byte(Settings.CookieSecret), nil)
 
+	var encoded string
 	cookie, err := ctx.Request.Cookie(COOKIE_NAME)
-	if err != nil { // no cookie found
+	if err == nil {
+		encoded = cookie.Value
+	} else if token, found := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer "); found {
+		encoded = token
+	} else { // no cookie found
 		return result, fmt.Errorf("finding the %s cookie: %w", COOKIE_NAME, err)
 	}
 
-	if err = sc.Decode(COOKIE_NAME, cookie.Value, &result); err != nil {
-		return result, fmt.Errorf("invalid cookie format: %w", err)
+	if err = sc.Decode(COOKIE_NAME, encoded, &result); err != nil {
+		return result, fmt.Errorf("%w: invalid cookie format: %w", errSessionExpired, err)
 	}
 
 	if err := validTimestamp(result.Timestamp); err != nil {
-		return result, fmt.Errorf("invalid timestamp: %w", err)
+		return result, err
 	}
 
 	return result, nil
@@ -114,6 +148,23 @@ func currentSession(ctx *gin.Context) (models.Session, error) {
 	return session, nil
 }
 
+// currentQuestionPool returns the cached question pool or parses the files if
+// there is no cache (e.g. in tests), with the questions voided by the admins
+// (see models.ApplyVoidDecisions)
+func currentQuestionPool() (models.QuestionPool, error) {
+	pool := models.QuestionPool{}
+	if Settings.QuestionPool != nil {
+		pool = Settings.QuestionPool.Pool()
+	} else {
+		var err error
+		if pool, err = models.NewQuestionPoolFromPath(Settings.QuestionPoolFile); err != nil {
+			return pool, err
+		}
+	}
+
+	return models.ApplyVoidDecisions(Settings.DB, pool)
+}
+
 func CreateCookie(email, userAgent string) (*http.Cookie, error) {
 	currentTimestamp := time.Now().Format(COOKIE_TIMESTAMP_FORMAT)
 	value := CookieValue{
@@ -137,11 +188,16 @@ func CreateCookie(email, userAgent string) (*http.Cookie, error) {
 	}, nil
 }
 
+// getQRCodePNG returns the QR code of the url, memoized. Only use it for
+// urls that don't change (e.g. not for the url of a game).
 func getQRCodePNG(url string) ([]byte, error) {
 	var png []byte
 	var cached bool
 	var err error
 
+	quizNewQRImageMu.Lock()
+	defer quizNewQRImageMu.Unlock()
+
 	png, cached = QuizNewQRImageMemoization[url]
 	if cached && len(QuizNewQRImageMemoization[url]) > 0 {
 		Settings.InfoLogger.Println("Using memoized QR code")
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jimmykarily/quizmaker/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	errInvalidEmail     = errors.New("invalid email")
+	errEmailUsed        = errors.New("email has already been used previously")
+	errOtherEmail       = errors.New("already started with another email")
+	errSessionExpired   = errors.New("session expired")
+	errNoAnswer         = errors.New("no answer submitted")
+	errNotYourQuestion  = errors.New("question doesn't belong to session")
+	errAdminDisabled    = errors.New("admin endpoints are disabled")
+	errNotAdmin         = errors.New("invalid admin token")
+	errInvalidCSRFToken = errors.New("missing or invalid CSRF token")
+	errQuizComplete     = errors.New("the quiz is complete")
+)
+
+type (
+	// errorBody is the body of the error responses of the "json" routes
+	errorBody struct {
+		Error errorDetails `json:"error"`
+	}
+
+	errorDetails struct {
+		Status  int    `json:"status"`
+		Reason  string `json:"reason"`  // the status text, e.g. "Not Found"
+		Message string `json:"message"` // see userMessage
+	}
+)
+
+// userMessage maps internal errors to messages that can be shown to the
+// participants. The internal error is only logged.
+func userMessage(err error) string {
+	switch {
+	case errors.Is(err, errInvalidEmail):
+		return "Please enter a valid email address."
+	case errors.Is(err, errEmailUsed):
+		return "This email has already been used to take the quiz."
+	case errors.Is(err, errOtherEmail):
+		return "You have already started the quiz with a different email."
+	case errors.Is(err, errSessionExpired), errors.Is(err, http.ErrNoCookie):
+		return "Your session has expired. Please start the quiz again."
+	case errors.Is(err, errNoAnswer):
+		return "Please select an answer before submitting."
+	case errors.Is(err, errNotYourQuestion):
+		return "This question is not part of your quiz."
+	case errors.Is(err, models.ErrQuestionExpired):
+		return "Time's up! Your answer arrived after the time ran out and was not counted."
+	case errors.Is(err, models.ErrQuestionAnswered):
+		return "You have already answered this question."
+	case errors.Is(err, models.ErrQuestionNotStarted):
+		return "This question hasn't been asked yet."
+	case errors.Is(err, models.ErrQuestionVoided):
+		return "This question was removed from the quiz."
+	case errors.Is(err, errAdminDisabled):
+		return "The admin area is disabled."
+	case errors.Is(err, errNotAdmin):
+		return "You need to log in as an admin."
+	case errors.Is(err, errInvalidCSRFToken):
+		return "The form has expired. Please reload the page and try again."
+	case errors.Is(err, errQuizComplete):
+		return "The quiz is complete, there are no more questions."
+	case errors.Is(err, errNotHost):
+		return "Only the host of the game can do that."
+	case errors.Is(err, models.ErrNotEnoughQuestions):
+		return "There are not enough questions for a game that long. Please pick fewer questions."
+	case errors.Is(err, models.ErrNicknameRequired):
+		return "Please pick a nickname."
+	case errors.Is(err, models.ErrNicknameTooLong):
+		return fmt.Sprintf("Please pick a nickname of at most %d characters.", models.MaxNicknameLength)
+	case errors.Is(err, models.ErrGameNotAsking):
+		return "No question is being asked right now."
+	case errors.Is(err, models.ErrGameFinished):
+		return "The game has finished."
+	case errors.Is(err, models.ErrInvalidAnswer):
+		return "Please pick one of the answers."
+	case errors.Is(err, models.ErrGameChanged):
+		return "The game has moved on in the meantime."
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return "We couldn't find what you were looking for."
+	default:
+		return "Something went wrong. Please try again."
+	}
+}
+
+// renderError logs the error and renders an error page with a message that
+// is safe to show to participants (see userMessage). Routes with the "json"
+// Format get an errorBody instead. It returns true if there was an error,
+// like handleError.
+func renderError(gctx *gin.Context, err error, code int) bool {
+	if err == nil {
+		return false
+	}
+	if Settings.ErrorLogger != nil { // we don't set it in tests
+		Settings.ErrorLogger.Println(err.Error())
+	}
+
+	if gctx.GetString(formatContextKey) == "json" {
+		gctx.JSON(code, errorBody{Error: errorDetails{
+			Status:  code,
+			Reason:  http.StatusText(code),
+			Message: userMessage(err),
+		}})
+		return true
+	}
+
+	homeURL, urlErr := GetFullURL(gctx.Request, "QuizNew", nil)
+	if urlErr != nil {
+		homeURL = "/"
+	}
+
+	viewData := struct {
+		Code    int
+		Status  string
+		Message string
+		HomeURL string
+	}{
+		Code:    code,
+		Status:  http.StatusText(code),
+		Message: userMessage(err),
+		HomeURL: homeURL,
+	}
+
+	gctx.Status(code)
+	RenderPage(gctx, []string{"main_layout", path.Join("errors", "show")}, viewData)
+	return true
+}
+
+// redirectWithError logs the error, queues a flash with a message that is
+// safe to show to participants and redirects to the given route.
+func redirectWithError(gctx *gin.Context, err error, routeName string) {
+	if Settings.ErrorLogger != nil { // we don't set it in tests
+		Settings.ErrorLogger.Println(err.Error())
+	}
+
+	redirectURL, urlErr := GetFullURL(gctx.Request, routeName, nil)
+	if renderError(gctx, urlErr, http.StatusInternalServerError) {
+		return
+	}
+
+	addFlash(gctx, FlashError, userMessage(err))
+	gctx.Redirect(http.StatusFound, redirectURL)
+}
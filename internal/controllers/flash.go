@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/securecookie"
+)
+
+const (
+	FLASH_COOKIE_NAME         = "quizmaker-flash"
+	FLASH_COOKIE_LIFETIME_SEC = 60
+
+	FlashError = "error"
+	FlashInfo  = "info"
+
+	flashContextKey = "quizmaker-flashes"
+)
+
+// Flash is a message shown once, on the next rendered page. Flashes survive
+// redirects because they are stored in a signed cookie.
+type Flash struct {
+	Kind    string // FlashError or FlashInfo
+	Message string
+}
+
+// addFlash queues a message for the next rendered page (usually after a
+// redirect).
+func addFlash(gctx *gin.Context, kind, message string) {
+	flashes := append(pendingFlashes(gctx), Flash{Kind: kind, Message: message})
+	gctx.Set(flashContextKey, flashes)
+
+	sc := securecookie.New([]byte(Settings.CookieSecret), nil)
+	encoded, err := sc.Encode(FLASH_COOKIE_NAME, flashes)
+	if err != nil {
+		if Settings.ErrorLogger != nil { // we don't set it in tests
+			Settings.ErrorLogger.Printf("encoding flash messages: %s\n", err.Error())
+		}
+		return
+	}
+
+	http.SetCookie(gctx.Writer, &http.Cookie{
+		Name:     FLASH_COOKIE_NAME,
+		Value:    encoded,
+		Path:     "/",
+		MaxAge:   FLASH_COOKIE_LIFETIME_SEC,
+		HttpOnly: true,
+	})
+}
+
+// popFlashes returns the pending flashes and deletes the cookie so that they
+// are only shown once. It should be called before writing the response body.
+func popFlashes(gctx *gin.Context) []Flash {
+	flashes := pendingFlashes(gctx)
+	if len(flashes) == 0 {
+		return flashes
+	}
+
+	gctx.Set(flashContextKey, []Flash{})
+	http.SetCookie(gctx.Writer, &http.Cookie{
+		Name:     FLASH_COOKIE_NAME,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	return flashes
+}
+
+// pendingFlashes returns the flashes added during this request or, if none,
+// the ones found in the request cookie. Invalid cookies are ignored.
+func pendingFlashes(gctx *gin.Context) []Flash {
+	if value, found := gctx.Get(flashContextKey); found {
+		return value.([]Flash)
+	}
+
+	flashes := []Flash{}
+	cookie, err := gctx.Request.Cookie(FLASH_COOKIE_NAME)
+	if err != nil {
+		return flashes
+	}
+	sc := securecookie.New([]byte(Settings.CookieSecret), nil)
+	if err := sc.Decode(FLASH_COOKIE_NAME, cookie.Value, &flashes); err != nil {
+		return []Flash{}
+	}
+	gctx.Set(flashContextKey, flashes)
+
+	return flashes
+}
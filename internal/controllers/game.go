@@ -0,0 +1,482 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/securecookie"
+	"github.com/jimmykarily/quizmaker/internal/events"
+	"github.com/jimmykarily/quizmaker/internal/models"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	GAME_HOST_COOKIE_NAME    = "quizmaker-game-host"
+	GAME_PLAYER_COOKIE_NAME  = "quizmaker-game-player"
+	GAME_COOKIE_LIFETIME_SEC = 6 * 3600
+	GAME_EVENT               = "game"
+	GAME_TOPIC_PREFIX        = "game:" // followed by the code, see RelayChange
+	DEFAULT_GAME_QUESTIONS   = 10
+	GAME_LEADERBOARD_SIZE    = 10
+)
+
+// the colors of the answer buttons, the same on the presenter screen and the phones
+var gameAnswerColors = []string{"bg-rose-500", "bg-sky-500", "bg-amber-500", "bg-emerald-500", "bg-violet-500", "bg-gray-500"}
+
+var errNotHost = errors.New("only the host can control the game")
+
+type (
+	// GameController runs the live games (see models.Game): the host
+	// controls the game from the presenter screen and the players answer
+	// on their phones.
+	GameController struct{}
+
+	gameCookieValue struct {
+		Code  string
+		Token string
+	}
+
+	// gameAnswer is an answer button (or bar on the presenter screen)
+	gameAnswer struct {
+		Number int
+		Text   string
+		Color  string
+		Count  int
+		Right  bool
+	}
+
+	// gameEvent is pushed to the presenter screen and the phones whenever
+	// the game changes
+	gameEvent struct {
+		State    models.GameState `json:"state"`
+		Current  int              `json:"current"`
+		Total    int              `json:"total"`
+		Players  []string         `json:"players"`
+		Answered int              `json:"answered"`
+	}
+)
+
+func (c *GameController) New(gctx *gin.Context) {
+	submitURL, err := GetFullURL(gctx.Request, "GameCreate", nil)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	viewData := struct {
+		SubmitURL        string
+		DefaultQuestions int
+	}{
+		SubmitURL:        submitURL,
+		DefaultQuestions: DEFAULT_GAME_QUESTIONS,
+	}
+
+	RenderPage(gctx, []string{"main_layout", path.Join("games", "new")}, viewData)
+}
+
+func (c *GameController) Create(gctx *gin.Context) {
+	qp, err := currentQuestionPool()
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	opts := models.QuizOptionsFor(qp, Settings.QuizOverrides)
+	opts.TotalQuestions = DEFAULT_GAME_QUESTIONS
+	opts.Categories = nil
+	if n, err := strconv.Atoi(gctx.PostForm("totalQuestions")); err == nil && n > 0 {
+		opts.TotalQuestions = n
+	}
+
+	game, err := models.NewGame(Settings.DB, opts)
+	if err != nil {
+		redirectWithError(gctx, err, "GameNew")
+		return
+	}
+
+	if renderError(gctx, setGameCookie(gctx, GAME_HOST_COOKIE_NAME, game.Code, game.HostToken), http.StatusInternalServerError) {
+		return
+	}
+
+	redirectToGame(gctx, "GameHost", game.Code)
+}
+
+// Host shows the presenter screen
+func (c *GameController) Host(gctx *gin.Context) {
+	game, ok := loadGame(gctx)
+	if !ok {
+		return
+	}
+	if !isGameHost(gctx, game) {
+		renderError(gctx, errNotHost, http.StatusUnauthorized)
+		return
+	}
+
+	params := map[string]string{"code": game.Code}
+	joinURL, err := GetFullURL(gctx.Request, "GameJoin", params)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+	png, err := qrcode.Encode(joinURL, qrcode.Medium, 512) // not memoized, every game has its own
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+	nextURL, err := GetFullURL(gctx.Request, "GameNext", params)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+	revealURL, err := GetFullURL(gctx.Request, "GameReveal", params)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+	eventsPath, err := GetRoutePath("GameEvents", params)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	question, _ := game.CurrentQuestion()
+	leaderboard := game.Leaderboard()
+	if len(leaderboard) > GAME_LEADERBOARD_SIZE {
+		leaderboard = leaderboard[:GAME_LEADERBOARD_SIZE]
+	}
+
+	viewData := struct {
+		Game        models.Game
+		Question    models.GameQuestion
+		Answers     []gameAnswer
+		TimeLeft    int
+		IsLast      bool
+		Leaderboard []models.GamePlayer
+		JoinURL     string
+		QRCodePNG   string
+		NextURL     string
+		RevealURL   string
+		EventsPath  string
+	}{
+		Game:        game,
+		Question:    question,
+		Answers:     gameAnswers(game, question),
+		TimeLeft:    int(time.Until(question.Deadline()).Seconds()),
+		IsLast:      game.Current >= len(game.Questions),
+		Leaderboard: leaderboard,
+		JoinURL:     joinURL,
+		QRCodePNG:   base64.StdEncoding.EncodeToString(png),
+		NextURL:     nextURL,
+		RevealURL:   revealURL,
+		EventsPath:  eventsPath,
+	}
+
+	RenderPage(gctx, []string{"main_layout", path.Join("games", "host")}, viewData)
+}
+
+// Next asks the next question (or finishes the game)
+func (c *GameController) Next(gctx *gin.Context) {
+	c.hostAction(gctx, func(game *models.Game) error {
+		return game.Next(Settings.DB, time.Now())
+	})
+}
+
+// Reveal shows the right answer of the current question
+func (c *GameController) Reveal(gctx *gin.Context) {
+	c.hostAction(gctx, func(game *models.Game) error {
+		return game.Reveal(Settings.DB, time.Now())
+	})
+}
+
+func (c *GameController) hostAction(gctx *gin.Context, action func(*models.Game) error) {
+	game, ok := loadGame(gctx)
+	if !ok {
+		return
+	}
+	if !isGameHost(gctx, game) {
+		renderError(gctx, errNotHost, http.StatusUnauthorized)
+		return
+	}
+
+	if err := action(&game); err != nil {
+		addFlash(gctx, FlashError, userMessage(err))
+	} else {
+		notifyGameChanged(game)
+	}
+
+	redirectToGame(gctx, "GameHost", game.Code)
+}
+
+// Join shows the form where players pick a nickname
+func (c *GameController) Join(gctx *gin.Context) {
+	game, ok := loadGame(gctx)
+	if !ok {
+		return
+	}
+	if _, found := game.Player(gameCookieToken(gctx, GAME_PLAYER_COOKIE_NAME, game.Code)); found {
+		redirectToGame(gctx, "GamePlay", game.Code)
+		return
+	}
+
+	submitURL, err := GetFullURL(gctx.Request, "GamePlayerCreate", map[string]string{"code": game.Code})
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	viewData := struct {
+		Game      models.Game
+		SubmitURL string
+	}{
+		Game:      game,
+		SubmitURL: submitURL,
+	}
+
+	RenderPage(gctx, []string{"main_layout", path.Join("games", "join")}, viewData)
+}
+
+func (c *GameController) CreatePlayer(gctx *gin.Context) {
+	game, ok := loadGame(gctx)
+	if !ok {
+		return
+	}
+
+	player, err := game.Join(Settings.DB, gctx.PostForm("nickname"))
+	if err != nil {
+		addFlash(gctx, FlashError, userMessage(err))
+		redirectToGame(gctx, "GameJoin", game.Code)
+		return
+	}
+	if renderError(gctx, setGameCookie(gctx, GAME_PLAYER_COOKIE_NAME, game.Code, player.Token), http.StatusInternalServerError) {
+		return
+	}
+	notifyGameChanged(game)
+
+	redirectToGame(gctx, "GamePlay", game.Code)
+}
+
+// Play shows the answer buttons on the phones of the players
+func (c *GameController) Play(gctx *gin.Context) {
+	game, ok := loadGame(gctx)
+	if !ok {
+		return
+	}
+	player, found := game.Player(gameCookieToken(gctx, GAME_PLAYER_COOKIE_NAME, game.Code))
+	if !found {
+		redirectToGame(gctx, "GameJoin", game.Code)
+		return
+	}
+
+	params := map[string]string{"code": game.Code}
+	answerURL, err := GetFullURL(gctx.Request, "GameAnswer", params)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+	eventsPath, err := GetRoutePath("GameEvents", params)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	question, _ := game.CurrentQuestion()
+	answer := question.AnswerOf(player.ID)
+	rank := 0
+	for _, p := range game.Leaderboard() {
+		if p.ID == player.ID {
+			player, rank = p, p.Rank
+		}
+	}
+
+	viewData := struct {
+		Game       models.Game
+		Player     models.GamePlayer
+		Rank       int
+		Answers    []gameAnswer
+		Answer     *models.GameAnswer
+		AnswerURL  string
+		EventsPath string
+	}{
+		Game:       game,
+		Player:     player,
+		Rank:       rank,
+		Answers:    gameAnswers(game, question),
+		Answer:     answer,
+		AnswerURL:  answerURL,
+		EventsPath: eventsPath,
+	}
+
+	RenderPage(gctx, []string{"main_layout", path.Join("games", "play")}, viewData)
+}
+
+func (c *GameController) Answer(gctx *gin.Context) {
+	game, ok := loadGame(gctx)
+	if !ok {
+		return
+	}
+	player, found := game.Player(gameCookieToken(gctx, GAME_PLAYER_COOKIE_NAME, game.Code))
+	if !found {
+		redirectToGame(gctx, "GameJoin", game.Code)
+		return
+	}
+
+	answer, err := strconv.Atoi(gctx.PostForm("answer"))
+	if err != nil {
+		err = errNoAnswer
+	} else {
+		err = game.Answer(Settings.DB, player, answer, time.Now(), Settings.AnswerGracePeriod)
+	}
+	if err != nil {
+		addFlash(gctx, FlashError, userMessage(err))
+	} else {
+		notifyGameChanged(game)
+	}
+
+	redirectToGame(gctx, "GamePlay", game.Code)
+}
+
+// Events streams the state of the game as server-sent events, so that the
+// presenter screen and the phones follow the host.
+func (c *GameController) Events(gctx *gin.Context) {
+	if Settings.GameEvents == nil {
+		handleError(gctx.Writer, errors.New("live updates are disabled"), http.StatusServiceUnavailable)
+		return
+	}
+	game, err := models.GameForCode(Settings.DB, gctx.Param("code"))
+	if handleError(gctx.Writer, err, http.StatusNotFound) {
+		return
+	}
+
+	stream, unsubscribe := Settings.GameEvents.Subscribe(game.Code)
+	defer unsubscribe()
+
+	current, err := json.Marshal(newGameEvent(game))
+	if handleError(gctx.Writer, err, http.StatusInternalServerError) {
+		return
+	}
+
+	streamEvents(gctx, events.Event{Name: GAME_EVENT, Data: current}, stream)
+}
+
+// notifyGameChanged reloads the game and pushes its state to the presenter
+// screen and the phones.
+func notifyGameChanged(game models.Game) {
+	notifyReplicas(GAME_TOPIC_PREFIX + game.Code)
+	publishGame(game.Code)
+}
+
+// publishGame pushes the state of the game to the presenter screen and the
+// phones connected to this replica
+func publishGame(code string) {
+	if Settings.GameEvents == nil {
+		return
+	}
+
+	game, err := models.GameForCode(Settings.DB, code)
+	if err == nil {
+		var data []byte
+		if data, err = json.Marshal(newGameEvent(game)); err == nil {
+			Settings.GameEvents.Publish(game.Code, events.Event{Name: GAME_EVENT, Data: data})
+			return
+		}
+	}
+	if Settings.ErrorLogger != nil { // we don't set it in tests
+		Settings.ErrorLogger.Printf("pushing the state of game %s: %s\n", code, err.Error())
+	}
+}
+
+func newGameEvent(game models.Game) gameEvent {
+	event := gameEvent{State: game.State, Current: game.Current, Total: len(game.Questions), Players: []string{}}
+	for _, p := range game.Players {
+		event.Players = append(event.Players, p.Nickname)
+	}
+	if question, found := game.CurrentQuestion(); found {
+		event.Answered = len(question.PlayerAnswers)
+	}
+
+	return event
+}
+
+// gameAnswers returns the answers of the question. The counts and the right
+// answer are only set once the question is revealed.
+func gameAnswers(game models.Game, question models.GameQuestion) []gameAnswer {
+	revealed := game.State == models.GameRevealing || game.State == models.GameFinished
+	distribution := question.Distribution()
+
+	result := []gameAnswer{}
+	for i, text := range question.Question.Answers {
+		a := gameAnswer{Number: i + 1, Text: text, Color: gameAnswerColors[i%len(gameAnswerColors)]}
+		if revealed {
+			a.Count = distribution[i]
+			a.Right = question.Question.CorrectAnswers().Contains(i + 1)
+		}
+		result = append(result, a)
+	}
+
+	return result
+}
+
+// loadGame loads the game of the "code" path parameter or renders an error
+func loadGame(gctx *gin.Context) (models.Game, bool) {
+	game, err := models.GameForCode(Settings.DB, gctx.Param("code"))
+	if renderError(gctx, err, http.StatusNotFound) {
+		return game, false
+	}
+
+	return game, true
+}
+
+func isGameHost(gctx *gin.Context, game models.Game) bool {
+	token := gameCookieToken(gctx, GAME_HOST_COOKIE_NAME, game.Code)
+
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(game.HostToken)) == 1
+}
+
+func redirectToGame(gctx *gin.Context, routeName, code string) {
+	redirectURL, err := GetFullURL(gctx.Request, routeName, map[string]string{"code": code})
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	gctx.Redirect(http.StatusFound, redirectURL)
+}
+
+// setGameCookie remembers the host or a player of a game in a signed cookie
+// (see CreateCookie). The cookie is only sent to the pages of that game.
+func setGameCookie(gctx *gin.Context, name, code, token string) error {
+	gamePath, err := GetRoutePath("GameJoin", map[string]string{"code": code})
+	if err != nil {
+		return err
+	}
+
+	sc := securecookie.New([]byte(Settings.CookieSecret), nil)
+	encoded, err := sc.Encode(name, gameCookieValue{Code: code, Token: token})
+	if err != nil {
+		return fmt.Errorf("failed to encode cookie: %w", err)
+	}
+
+	http.SetCookie(gctx.Writer, &http.Cookie{
+		Name:     name,
+		Value:    encoded,
+		Path:     gamePath,
+		MaxAge:   GAME_COOKIE_LIFETIME_SEC,
+		HttpOnly: true,
+	})
+
+	return nil
+}
+
+// gameCookieToken returns the token in the given cookie if it's valid and for
+// the given game, otherwise an empty string.
+func gameCookieToken(gctx *gin.Context, name, code string) string {
+	cookie, err := gctx.Request.Cookie(name)
+	if err != nil {
+		return ""
+	}
+
+	var value gameCookieValue
+	sc := securecookie.New([]byte(Settings.CookieSecret), nil)
+	if err := sc.Decode(name, cookie.Value, &value); err != nil || value.Code != code {
+		return ""
+	}
+
+	return value.Token
+}
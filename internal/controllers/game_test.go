@@ -0,0 +1,178 @@
+package controllers_test
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jimmykarily/quizmaker/internal/controllers"
+	"github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GameController test", func() {
+	var router *gin.Engine
+	var host, alice map[string]*http.Cookie
+
+	// request performs a request with the given cookies (a browser) and
+	// stores the cookies of the response. Redirects are not followed.
+	request := func(browser map[string]*http.Cookie, verb, routeName, code string, form url.Values) *httptest.ResponseRecorder {
+		path, err := controllers.GetRoutePath(routeName, map[string]string{"code": code})
+		Expect(err).ToNot(HaveOccurred())
+		req, err := http.NewRequest(verb, path, strings.NewReader(form.Encode()))
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range browser {
+			req.AddCookie(c)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		for _, c := range w.Result().Cookies() {
+			browser[c.Name] = c
+		}
+
+		return w
+	}
+
+	createGame := func() models.Game {
+		w := request(host, "POST", "GameCreate", "", url.Values{"totalQuestions": {"2"}})
+		Expect(w.Code).To(Equal(http.StatusFound))
+
+		game := models.Game{}
+		Expect(controllers.Settings.DB.Last(&game).Error).ToNot(HaveOccurred())
+		Expect(w.Header().Get("Location")).To(HaveSuffix("/games/" + game.Code + "/host"))
+		Expect(host).To(HaveKey(controllers.GAME_HOST_COOKIE_NAME))
+
+		return game
+	}
+
+	BeforeEach(func() {
+		router = gin.Default()
+		controllers.SetupRoutes(router, controllers.GetRoutes())
+
+		controllers.Settings.QuestionPoolFile =
+			filepath.Join(currentDir, "tests/assets/question_pool.yaml")
+		controllers.Settings.InfoLogger = log.New(io.Discard, "", 0)
+
+		host = map[string]*http.Cookie{}
+		alice = map[string]*http.Cookie{}
+	})
+
+	AfterEach(func() {
+		controllers.Settings.InfoLogger = nil
+	})
+
+	It("lets the host run the game and the players answer", func() {
+		game := createGame()
+
+		w := request(host, "GET", "GameHost", game.Code, nil)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(ContainSubstring(game.Code))
+		Expect(w.Body.String()).To(ContainSubstring("0</span> players joined"))
+
+		w = request(alice, "GET", "GameJoin", game.Code, nil)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(ContainSubstring("Your nickname"))
+
+		w = request(alice, "POST", "GamePlayerCreate", game.Code, url.Values{"nickname": {"alice"}})
+		Expect(w.Code).To(Equal(http.StatusFound))
+		Expect(w.Header().Get("Location")).To(HaveSuffix("/games/" + game.Code + "/play"))
+
+		w = request(alice, "GET", "GamePlay", game.Code, nil)
+		Expect(w.Body.String()).To(ContainSubstring("Waiting for the host to start the game"))
+
+		w = request(host, "POST", "GameNext", game.Code, nil)
+		Expect(w.Code).To(Equal(http.StatusFound))
+
+		game, err := models.GameForCode(controllers.Settings.DB, game.Code)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(game.State).To(Equal(models.GameAsking))
+		question, _ := game.CurrentQuestion()
+
+		w = request(host, "GET", "GameHost", game.Code, nil)
+		Expect(w.Body.String()).To(ContainSubstring(question.Question.Text))
+
+		w = request(alice, "GET", "GamePlay", game.Code, nil)
+		Expect(w.Body.String()).ToNot(ContainSubstring(question.Question.Text))
+		Expect(w.Body.String()).To(ContainSubstring(`name="answer" value="1"`))
+
+		right := question.Question.CorrectAnswers()[0]
+		w = request(alice, "POST", "GameAnswer", game.Code, url.Values{"answer": {fmt.Sprint(right)}})
+		Expect(w.Code).To(Equal(http.StatusFound))
+
+		w = request(alice, "GET", "GamePlay", game.Code, nil)
+		Expect(w.Body.String()).To(ContainSubstring("Waiting for the others"))
+
+		w = request(host, "POST", "GameReveal", game.Code, nil)
+		Expect(w.Code).To(Equal(http.StatusFound))
+
+		w = request(alice, "GET", "GamePlay", game.Code, nil)
+		Expect(w.Body.String()).To(ContainSubstring("Correct!"))
+		Expect(w.Body.String()).To(ContainSubstring("You're #1 of 1"))
+	})
+
+	It("doesn't let the players control the game", func() {
+		game := createGame()
+
+		request(alice, "POST", "GamePlayerCreate", game.Code, url.Values{"nickname": {"alice"}})
+		w := request(alice, "POST", "GameNext", game.Code, nil)
+		Expect(w.Code).To(Equal(http.StatusUnauthorized))
+		Expect(w.Body.String()).To(ContainSubstring("Only the host of the game can do that."))
+
+		game, err := models.GameForCode(controllers.Settings.DB, game.Code)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(game.State).To(Equal(models.GameLobby))
+	})
+
+	It("shows a message when answering while no question is asked", func() {
+		game := createGame()
+
+		request(alice, "POST", "GamePlayerCreate", game.Code, url.Values{"nickname": {"alice"}})
+		w := request(alice, "POST", "GameAnswer", game.Code, url.Values{"answer": {"1"}})
+		Expect(w.Code).To(Equal(http.StatusFound))
+
+		w = request(alice, "GET", "GamePlay", game.Code, nil)
+		Expect(w.Body.String()).To(ContainSubstring("No question is being asked right now."))
+	})
+
+	It("sends players without a nickname back to the join page", func() {
+		game := createGame()
+
+		w := request(alice, "GET", "GamePlay", game.Code, nil)
+		Expect(w.Code).To(Equal(http.StatusFound))
+		Expect(w.Header().Get("Location")).To(HaveSuffix("/games/" + game.Code))
+	})
+
+	It("escapes the nicknames and rejects long ones", func() {
+		game := createGame()
+		w := request(alice, "POST", "GamePlayerCreate", game.Code, url.Values{"nickname": {"<script>alert(1)</script>"}})
+		Expect(w.Code).To(Equal(http.StatusFound))
+		Expect(w.Header().Get("Location")).To(HaveSuffix("/games/" + game.Code + "/play"))
+
+		w = request(host, "GET", "GameHost", game.Code, nil)
+		Expect(w.Body.String()).To(ContainSubstring("&lt;script&gt;alert(1)&lt;/script&gt;"))
+		Expect(w.Body.String()).ToNot(ContainSubstring("<script>alert(1)"))
+
+		bob := map[string]*http.Cookie{}
+		w = request(bob, "POST", "GamePlayerCreate", game.Code, url.Values{"nickname": {strings.Repeat("b", 31)}})
+		Expect(w.Header().Get("Location")).To(HaveSuffix("/games/" + game.Code))
+		var players int64
+		Expect(controllers.Settings.DB.Model(&models.GamePlayer{}).Count(&players).Error).To(Succeed())
+		Expect(players).To(BeEquivalentTo(1))
+	})
+
+	It("shows a friendly error for unknown games", func() {
+		w := request(alice, "GET", "GameJoin", "NOPE42", nil)
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+		Expect(w.Body.String()).To(ContainSubstring(html.EscapeString("We couldn't find what you were looking for.")))
+	})
+})
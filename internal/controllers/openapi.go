@@ -0,0 +1,325 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	OPENAPI_VERSION = "3.0.3"
+	API_VERSION     = "1.0.0"
+
+	// the values of APIDoc.Auth
+	AuthSession = "session"
+	AuthAdmin   = "admin"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+type (
+	// APIDoc describes a route in the OpenAPI document (see OpenAPIDocument).
+	// Request and the Responses are zero values of the Go types that are
+	// (un)marshalled, their schemas are derived from the types.
+	APIDoc struct {
+		Summary string
+		Auth    string   // AuthSession, AuthAdmin or empty for public routes
+		Query   []string // optional string query parameters
+		Form    []string // optional string form fields (instead of a JSON Request)
+		Request interface{}
+		// Responses by status code. A nil value means a body that is not
+		// JSON (see Route.Format), e.g. CSV.
+		Responses map[int]interface{}
+	}
+
+	// OpenAPI is an OpenAPI 3 document. Only the parts that quizmaker uses are
+	// modeled.
+	OpenAPI struct {
+		OpenAPI    string                       `json:"openapi"`
+		Info       OpenAPIInfo                  `json:"info"`
+		Paths      map[string]map[string]*APIOp `json:"paths"`
+		Components OpenAPIComponents            `json:"components"`
+	}
+
+	OpenAPIInfo struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	}
+
+	OpenAPIComponents struct {
+		Schemas         map[string]*Schema           `json:"schemas"`
+		SecuritySchemes map[string]map[string]string `json:"securitySchemes"`
+	}
+
+	// APIOp is an operation (a route) in an OpenAPI document
+	APIOp struct {
+		OperationID string                `json:"operationId"`
+		Summary     string                `json:"summary,omitempty"`
+		Parameters  []APIParameter        `json:"parameters,omitempty"`
+		RequestBody *APIBody              `json:"requestBody,omitempty"`
+		Responses   map[string]APIBody    `json:"responses"`
+		Security    []map[string][]string `json:"security,omitempty"`
+	}
+
+	APIParameter struct {
+		Name     string  `json:"name"`
+		In       string  `json:"in"`
+		Required bool    `json:"required"`
+		Schema   *Schema `json:"schema"`
+	}
+
+	APIBody struct {
+		Description string                `json:"description,omitempty"`
+		Content     map[string]APIContent `json:"content,omitempty"`
+	}
+
+	APIContent struct {
+		Schema *Schema `json:"schema"`
+	}
+
+	// Schema is a JSON schema as used by OpenAPI 3.0
+	Schema struct {
+		Ref                  string             `json:"$ref,omitempty"`
+		Type                 string             `json:"type,omitempty"`
+		Format               string             `json:"format,omitempty"`
+		Nullable             bool               `json:"nullable,omitempty"`
+		Items                *Schema            `json:"items,omitempty"`
+		Properties           map[string]*Schema `json:"properties,omitempty"`
+		Required             []string           `json:"required,omitempty"`
+		AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	}
+)
+
+// ShowOpenAPI serves the OpenAPI document of the routes
+func ShowOpenAPI(gctx *gin.Context) {
+	doc, err := OpenAPIDocument(GetRoutes())
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	gctx.JSON(http.StatusOK, doc)
+}
+
+// OpenAPIDocument derives the OpenAPI document from the routes that have an
+// APIDoc. The errors of JSON routes are documented as the "default" response
+// (see renderError).
+func OpenAPIDocument(routes Routes) (OpenAPI, error) {
+	doc := OpenAPI{
+		OpenAPI: OPENAPI_VERSION,
+		Info:    OpenAPIInfo{Title: "quizmaker", Version: API_VERSION},
+		Paths:   map[string]map[string]*APIOp{},
+		Components: OpenAPIComponents{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]map[string]string{
+				"sessionToken": {"type": "http", "scheme": "bearer"},
+				"adminToken":   {"type": "http", "scheme": "bearer"},
+				"adminBasic":   {"type": "http", "scheme": "basic"},
+			},
+		},
+	}
+	schemas := schemaRegistry{schemas: doc.Components.Schemas, types: map[string]reflect.Type{}}
+
+	errorSchema, err := schemas.schemaFor(reflect.TypeOf(errorBody{}))
+	if err != nil {
+		return doc, err
+	}
+
+	for _, r := range routes {
+		if r.Doc == nil {
+			continue
+		}
+
+		path, params := openAPIPath(r.Path)
+		op := &APIOp{
+			OperationID: r.Name,
+			Summary:     r.Doc.Summary,
+			Parameters:  params,
+			Responses:   map[string]APIBody{},
+		}
+		if r.Format == "json" {
+			op.Responses["default"] = APIBody{Description: "Error", Content: map[string]APIContent{"application/json": {Schema: errorSchema}}}
+		}
+		for _, q := range r.Doc.Query {
+			op.Parameters = append(op.Parameters, APIParameter{Name: q, In: "query", Schema: &Schema{Type: "string"}})
+		}
+
+		switch r.Doc.Auth {
+		case AuthSession:
+			op.Security = []map[string][]string{{"sessionToken": {}}}
+		case AuthAdmin:
+			op.Security = []map[string][]string{{"adminToken": {}}, {"adminBasic": {}}}
+		}
+
+		if r.Doc.Request != nil {
+			schema, err := schemas.schemaFor(reflect.TypeOf(r.Doc.Request))
+			if err != nil {
+				return doc, fmt.Errorf("route %s: %w", r.Name, err)
+			}
+			op.RequestBody = &APIBody{Content: map[string]APIContent{"application/json": {Schema: schema}}}
+		} else if len(r.Doc.Form) > 0 {
+			form := &Schema{Type: "object", Properties: map[string]*Schema{}}
+			for _, f := range r.Doc.Form {
+				form.Properties[f] = &Schema{Type: "string"}
+			}
+			op.RequestBody = &APIBody{Content: map[string]APIContent{"application/x-www-form-urlencoded": {Schema: form}}}
+		}
+
+		for code, example := range r.Doc.Responses {
+			body := APIBody{Description: http.StatusText(code)}
+			if example == nil {
+				body.Content = map[string]APIContent{formatContentType(r.Format): {Schema: &Schema{Type: "string"}}}
+			} else {
+				schema, err := schemas.schemaFor(reflect.TypeOf(example))
+				if err != nil {
+					return doc, fmt.Errorf("route %s: %w", r.Name, err)
+				}
+				body.Content = map[string]APIContent{"application/json": {Schema: schema}}
+			}
+			op.Responses[strconv.Itoa(code)] = body
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*APIOp{}
+		}
+		doc.Paths[path][strings.ToLower(r.Method)] = op
+	}
+
+	return doc, nil
+}
+
+// openAPIPath converts the path parameters from the gin syntax (":id") to
+// the OpenAPI one ("{id}")
+func openAPIPath(ginPath string) (string, []APIParameter) {
+	params := []APIParameter{}
+	parts := strings.Split(ginPath, "/")
+	for i, part := range parts {
+		if name, found := strings.CutPrefix(part, ":"); found {
+			parts[i] = "{" + name + "}"
+			params = append(params, APIParameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+
+	return strings.Join(parts, "/"), params
+}
+
+func formatContentType(format string) string {
+	switch format {
+	case "csv":
+		return "text/csv"
+	case "json":
+		return "application/json"
+	default:
+		return "text/plain"
+	}
+}
+
+// schemaRegistry derives schemas from Go types. Structs are added to the
+// components of the document and referenced.
+type schemaRegistry struct {
+	schemas map[string]*Schema
+	types   map[string]reflect.Type // to detect different types with the same name
+}
+
+func (r schemaRegistry) schemaFor(t reflect.Type) (*Schema, error) {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case t.Kind() == reflect.Pointer:
+		schema, err := r.schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if schema.Ref != "" { // $ref can't have siblings in OpenAPI 3.0
+			return schema, nil
+		}
+		nullable := *schema
+		nullable.Nullable = true
+		return &nullable, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := r.schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Struct:
+		return r.structSchema(t)
+	default:
+		return nil, fmt.Errorf("no schema for type %s", t)
+	}
+}
+
+// structSchema adds the schema of the struct to the components and returns a
+// reference to it. Fields with "omitempty" are optional, the rest required.
+// Unknown properties are not allowed, so that the document is a strict
+// contract.
+func (r schemaRegistry) structSchema(t reflect.Type) (*Schema, error) {
+	name := schemaName(t)
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if existing, found := r.types[name]; found {
+		if existing != t {
+			return nil, fmt.Errorf("types %s and %s have the same schema name", existing, t)
+		}
+		return ref, nil
+	}
+	r.types[name] = t
+
+	noAdditional := false
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}, Required: []string{}, AdditionalProperties: &noAdditional}
+	r.schemas[name] = schema // before the fields, in case of recursion
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		jsonName, options, _ := strings.Cut(tag, ",")
+		if jsonName == "" {
+			jsonName = field.Name
+		}
+
+		fieldSchema, err := r.schemaFor(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s of %s: %w", field.Name, t, err)
+		}
+		schema.Properties[jsonName] = fieldSchema
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, jsonName)
+		}
+	}
+	sort.Strings(schema.Required)
+
+	return ref, nil
+}
+
+// schemaName returns the name of the type without the "api" prefix of the
+// API types, capitalized (e.g. apiSession is "Session")
+func schemaName(t reflect.Type) string {
+	name := strings.TrimPrefix(t.Name(), "api")
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+
+	return string(runes)
+}
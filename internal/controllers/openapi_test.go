@@ -0,0 +1,274 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jimmykarily/quizmaker/internal/controllers"
+	"github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// validateSchema returns how the decoded JSON value differs from the schema
+// of the OpenAPI document. Only the parts of JSON schema that
+// controllers.OpenAPIDocument produces are supported.
+func validateSchema(doc, schema map[string]interface{}, value interface{}, at string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+		resolved, found := schemas[strings.TrimPrefix(ref, "#/components/schemas/")]
+		if !found {
+			return []string{fmt.Sprintf("%s: unknown schema %s", at, ref)}
+		}
+		return validateSchema(doc, resolved.(map[string]interface{}), value, at)
+	}
+
+	if value == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: is null, expected %v", at, schema["type"])}
+	}
+
+	mismatch := []string{fmt.Sprintf("%s: %v is not of type %v", at, value, schema["type"])}
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return mismatch
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]interface{})
+
+		errs := []string{}
+		for _, name := range required {
+			if _, found := object[name.(string)]; !found {
+				errs = append(errs, fmt.Sprintf("%s: required property %q is missing", at, name))
+			}
+		}
+		for name, v := range object {
+			property, found := properties[name]
+			if !found {
+				if schema["additionalProperties"] == false {
+					errs = append(errs, fmt.Sprintf("%s: property %q is not in the document", at, name))
+				}
+				continue
+			}
+			errs = append(errs, validateSchema(doc, property.(map[string]interface{}), v, at+"."+name)...)
+		}
+		return errs
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return mismatch
+		}
+		errs := []string{}
+		for i, v := range array {
+			errs = append(errs, validateSchema(doc, schema["items"].(map[string]interface{}), v, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+		return errs
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return mismatch
+		}
+		if _, err := time.Parse(time.RFC3339, s); schema["format"] == "date-time" && err != nil {
+			return []string{fmt.Sprintf("%s: %q is not a date-time", at, s)}
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			return mismatch
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return mismatch
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return mismatch
+		}
+	}
+
+	return nil
+}
+
+var _ = Describe("OpenAPI document", func() {
+	var router *gin.Engine
+	var doc map[string]interface{}
+	// the documented responses the test received, as "<operationId> <code>"
+	var received map[string]bool
+
+	ginParam := regexp.MustCompile(`:(\w+)`)
+
+	// request performs the request of a route and checks that the response
+	// matches the document. The body is sent as a form when it's url.Values and
+	// as JSON otherwise.
+	request := func(verb, routeName string, params map[string]string, token string, body interface{}) (int, interface{}) {
+		route, err := controllers.RouteByName(routeName)
+		Expect(err).ToNot(HaveOccurred())
+		path, err := controllers.GetRoutePath(routeName, params)
+		Expect(err).ToNot(HaveOccurred())
+
+		var req *http.Request
+		if form, ok := body.(url.Values); ok {
+			req, err = http.NewRequest(verb, path, strings.NewReader(form.Encode()))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			encoded, err := json.Marshal(body)
+			Expect(err).ToNot(HaveOccurred())
+			req, err = http.NewRequest(verb, path, bytes.NewReader(encoded))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		operations, found := doc["paths"].(map[string]interface{})[ginParam.ReplaceAllString(route.Path, "{$1}")]
+		Expect(found).To(BeTrue(), "%s is not in the document", route.Path)
+		operation := operations.(map[string]interface{})[strings.ToLower(verb)].(map[string]interface{})
+		responses := operation["responses"].(map[string]interface{})
+		response, found := responses[strconv.Itoa(w.Code)]
+		if found {
+			received[fmt.Sprintf("%s %d", routeName, w.Code)] = true
+		} else {
+			response, found = responses["default"]
+		}
+		Expect(found).To(BeTrue(), "%s %d is not documented: %s", routeName, w.Code, w.Body.String())
+
+		content := response.(map[string]interface{})["content"].(map[string]interface{})
+		Expect(content).To(HaveKey("application/json"))
+		var decoded interface{}
+		Expect(json.Unmarshal(w.Body.Bytes(), &decoded)).To(Succeed(), w.Body.String())
+		schema := content["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+		Expect(validateSchema(doc, schema, decoded, routeName)).To(BeEmpty(), w.Body.String())
+
+		return w.Code, decoded
+	}
+
+	BeforeEach(func() {
+		router = gin.Default()
+		controllers.SetupRoutes(router, controllers.GetRoutes())
+
+		controllers.Settings.QuestionPoolFile =
+			filepath.Join(currentDir, "tests/assets/question_pool.yaml")
+		controllers.Settings.QuizOverrides = models.QuizConfig{TotalQuestions: 2}
+		controllers.Settings.AdminToken = "secret-token"
+
+		req, err := http.NewRequest("GET", "/openapi.json", nil)
+		Expect(err).ToNot(HaveOccurred())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusOK), w.Body.String())
+		doc = map[string]interface{}{}
+		Expect(json.Unmarshal(w.Body.Bytes(), &doc)).To(Succeed())
+
+		received = map[string]bool{}
+	})
+
+	AfterEach(func() {
+		controllers.Settings.QuizOverrides = models.QuizConfig{}
+		controllers.Settings.AdminToken = ""
+	})
+
+	It("documents every JSON route", func() {
+		Expect(doc).To(HaveKeyWithValue("openapi", "3.0.3"))
+		for _, r := range controllers.GetRoutes() {
+			if r.Format == "json" && r.Name != "OpenAPI" {
+				Expect(r.Doc).ToNot(BeNil(), "route %s has no Doc", r.Name)
+			}
+		}
+
+		paths := doc["paths"].(map[string]interface{})
+		Expect(paths).To(HaveKey("/api/v1/questions/{id}/answer"))
+		Expect(paths).To(HaveKey("/admin/export.csv"))
+		Expect(paths).ToNot(HaveKey("/quiz"))
+	})
+
+	It("matches the responses of the handlers", func() {
+		code, body := request("POST", "APISessionCreate", nil, "", map[string]string{"email": "alice@example.com"})
+		Expect(code).To(Equal(http.StatusCreated))
+		token := body.(map[string]interface{})["token"].(string)
+
+		code, _ = request("POST", "APISessionCreate", nil, "", map[string]string{"email": "alice@example.com"})
+		Expect(code).To(Equal(http.StatusConflict))
+		code, _ = request("GET", "APISessionShow", nil, "", nil)
+		Expect(code).To(Equal(http.StatusUnauthorized))
+
+		var poolID string
+		for i := 0; i < 2; i++ {
+			code, body = request("GET", "APIQuestionShow", nil, token, nil)
+			Expect(code).To(Equal(http.StatusOK))
+			id := body.(map[string]interface{})["question"].(map[string]interface{})["id"]
+
+			var persisted models.Question
+			Expect(controllers.Settings.DB.First(&persisted, id).Error).ToNot(HaveOccurred())
+			poolID = persisted.PoolID
+			code, _ = request("POST", "APIQuestionAnswer", map[string]string{"id": fmt.Sprint(id)}, token,
+				map[string]int{"answer": persisted.CorrectAnswers()[0]})
+			Expect(code).To(Equal(http.StatusOK))
+
+			code, _ = request("GET", "APISessionShow", nil, token, nil)
+			Expect(code).To(Equal(http.StatusOK))
+		}
+
+		code, _ = request("GET", "APIQuestionShow", nil, token, nil)
+		Expect(code).To(Equal(http.StatusNotFound))
+		code, _ = request("GET", "APILeaderboard", nil, "", nil)
+		Expect(code).To(Equal(http.StatusOK))
+
+		code, _ = request("GET", "AdminExportJSON", nil, "secret-token", nil)
+		Expect(code).To(Equal(http.StatusOK))
+		code, _ = request("POST", "AdminRegrade", nil, "secret-token", nil)
+		Expect(code).To(Equal(http.StatusOK))
+		code, _ = request("POST", "AdminQuestionVoid", map[string]string{"id": poolID}, "secret-token", url.Values{"voided": {"true"}})
+		Expect(code).To(Equal(http.StatusOK))
+		code, _ = request("POST", "AdminRegrade", nil, "wrong-token", nil)
+		Expect(code).To(Equal(http.StatusUnauthorized))
+
+		// Every documented JSON response should be checked here
+		for path, operations := range doc["paths"].(map[string]interface{}) {
+			for _, operation := range operations.(map[string]interface{}) {
+				operation := operation.(map[string]interface{})
+				for code, response := range operation["responses"].(map[string]interface{}) {
+					content, _ := response.(map[string]interface{})["content"].(map[string]interface{})
+					if code == "default" || content["application/json"] == nil {
+						continue
+					}
+					Expect(received).To(HaveKey(fmt.Sprintf("%s %s", operation["operationId"], code)),
+						"the %s response of %s wasn't checked", code, path)
+				}
+			}
+		}
+	})
+
+	It("notices responses that drift from the document", func() {
+		schema := map[string]interface{}{"$ref": "#/components/schemas/Session"}
+		session := map[string]interface{}{
+			"email": "alice@example.com", "nickname": "alice", "score": 1.0, "points": 1.5,
+			"complete": "yes", "answered": 1.0, "startedAt": time.Now().Format(time.RFC3339), "unknown": true,
+		}
+
+		Expect(validateSchema(doc, schema, session, "session")).To(ConsistOf(
+			`session: required property "total" is missing`,
+			`session: property "unknown" is not in the document`,
+			"session.points: 1.5 is not of type integer",
+			"session.complete: yes is not of type boolean",
+		))
+	})
+})
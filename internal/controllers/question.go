@@ -4,10 +4,11 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jimmykarily/quizmaker/internal/models"
-	"gorm.io/gorm/clause"
 )
 
 type (
@@ -16,60 +17,130 @@ type (
 
 func (c *QuestionController) Answer(gctx *gin.Context) {
 	session, err := currentSession(gctx)
-	if handleError(gctx.Writer, err, http.StatusUnauthorized) {
+	if renderError(gctx, err, http.StatusUnauthorized) {
 		return
 	}
 
 	err = gctx.Request.ParseForm()
-	if handleError(gctx.Writer, err, http.StatusBadRequest) {
+	if renderError(gctx, err, http.StatusBadRequest) {
 		return
 	}
 
 	qid := gctx.Param("id")
-	selectedAnswer := gctx.Request.FormValue("answer")
 
 	var question models.Question
 	err = Settings.DB.First(&question, "ID = ?", qid).Error
-	if handleError(gctx.Writer, err, http.StatusNotFound) {
+	if renderError(gctx, err, http.StatusNotFound) {
 		return
 	}
 
 	// If the question doesn't belong to the current session
-	if question.SessionEmail != session.Email {
-		handleError(gctx.Writer, errors.New("question doesn't belong to session"), http.StatusUnauthorized)
+	if question.SessionID != session.ID {
+		renderError(gctx, errNotYourQuestion, http.StatusForbidden)
+		return
 	}
 
-	// Don't allow answering expired or already answered questions
-	if question.Expired() || question.UserAnswer != 0 {
-		// TODO: Flash error
-	} else {
-		question.UserAnswer, err = strconv.Atoi(selectedAnswer)
-		if handleError(gctx.Writer, err, http.StatusBadRequest) {
+	if code, err := submitAnswer(&session, &question, gctx.Request.Form["answer"], time.Now()); err != nil {
+		if code == http.StatusConflict { // too late or answered already
+			redirectWithError(gctx, err, "QuizShow")
 			return
 		}
+		renderError(gctx, err, code)
+		return
+	}
 
-		err = Settings.DB.Save(&question).Error
-		if handleError(gctx.Writer, err, http.StatusInternalServerError) {
-			return
+	redirectURL, err := GetFullURL(gctx.Request, "QuizShow", nil)
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
+
+	gctx.Redirect(http.StatusFound, redirectURL)
+}
+
+// submitAnswer records the submitted answer values on the question of the
+// session and updates the score of the session. Expired or already answered
+// questions are not accepted: the countdown in the page is only a hint, the
+// server decides. On errors, it returns the status code that fits.
+func submitAnswer(session *models.Session, question *models.Question, values []string, now time.Time) (int, error) {
+	if err := question.CheckAnswerAllowed(now, Settings.AnswerGracePeriod); err != nil {
+		if errors.Is(err, models.ErrQuestionExpired) {
+			question.ExpiredAt = question.Deadline()
+			if err := Settings.DB.Model(question).Update("expired_at", question.ExpiredAt).Error; err != nil {
+				return http.StatusInternalServerError, err
+			}
+			if err := updateSessionScore(session); err != nil {
+				return http.StatusInternalServerError, err
+			}
 		}
+		return http.StatusConflict, err
+	}
 
-		// reload session
-		err = Settings.DB.Preload(clause.Associations).Find(&session).Error
-		if handleError(gctx.Writer, err, http.StatusInternalServerError) {
-			return
+	if err := setSubmittedAnswer(question, values); err != nil {
+		return http.StatusBadRequest, err
+	}
+	question.AnsweredAt = now
+	question.ExpiredAt = time.Time{} // in case it was recorded during the grace period
+
+	if err := Settings.DB.Save(question).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err := updateSessionScore(session); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
+
+// updateSessionScore reloads the questions of the session, recalculates
+// the cached columns (see models.Session.Refresh) and pushes the new
+// leaderboard.
+func updateSessionScore(session *models.Session) error {
+	if _, _, err := session.Refresh(Settings.DB, time.Now(), 0); err != nil {
+		return err
+	}
+	NotifyLeaderboardChanged()
+
+	return nil
+}
+
+// setSubmittedAnswer parses the submitted "answer" form values and stores them
+// on the question. Multiple-choice questions accept more than one value.
+func setSubmittedAnswer(question *models.Question, values []string) error {
+	if len(values) == 0 {
+		return errNoAnswer
+	}
+
+	if question.IsFreeInput() {
+		answer := strings.TrimSpace(values[0])
+		if answer == "" {
+			return errNoAnswer
 		}
-		s := &session
-		s.UpdateCacheColumns()
-		err = Settings.DB.Save(s).Error
-		if handleError(gctx.Writer, err, http.StatusInternalServerError) {
-			return
+		question.UserTextAnswer = answer
+
+		return nil
+	}
+
+	if !question.IsMultipleChoice() {
+		answer, err := strconv.Atoi(values[0])
+		if err != nil {
+			return err
 		}
+		question.UserAnswer = answer
+
+		return nil
 	}
 
-	redirectURL, err := GetFullURL(gctx.Request, "QuizShow", nil)
-	if handleError(gctx.Writer, err, http.StatusInternalServerError) {
-		return
+	answers := models.AnswerSet{}
+	for _, v := range values {
+		answer, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		if !answers.Contains(answer) {
+			answers = append(answers, answer)
+		}
 	}
+	question.UserAnswers = answers
 
-	gctx.Redirect(http.StatusFound, redirectURL)
+	return nil
 }
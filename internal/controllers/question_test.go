@@ -1,8 +1,10 @@
 package controllers_test
 
 import (
+	"html"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
 	"time"
 
@@ -57,6 +59,23 @@ var _ = Describe("QuestionController test", func() {
 			})
 		})
 
+		When("the question doesn't exist", func() {
+			It("shows a friendly error page", func() {
+				email := "john.doe@example.com"
+				cookie, err := controllers.CreateCookie(email, "Firefox")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(controllers.Settings.DB.Create(&models.Session{Email: email}).Error).ToNot(HaveOccurred())
+
+				path, err := controllers.GetRoutePath("QuestionAnswer", map[string]string{"id": "12345"})
+				Expect(err).ToNot(HaveOccurred())
+
+				w, _ = performPostWithParams(router, "POST", path, map[string]string{"answer": "1"}, cookie)
+				Expect(w.Code).To(Equal(http.StatusNotFound))
+				Expect(w.Body.String()).To(ContainSubstring(html.EscapeString("We couldn't find what you were looking for.")))
+				Expect(w.Body.String()).ToNot(ContainSubstring("record not found"))
+			})
+		})
+
 		When("there is an active session", func() {
 			var cookie *http.Cookie
 			var err error
@@ -72,16 +91,18 @@ var _ = Describe("QuestionController test", func() {
 			})
 			When("the question doesn't belong to the current session", func() {
 				BeforeEach(func() {
+					other := models.Session{Email: "someonelse@example.com"}
+					Expect(controllers.Settings.DB.Create(&other).Error).ToNot(HaveOccurred())
 					question = models.Question{
-						SessionEmail: "someonelse@example.com",
-						Text:         "some question",
-						StartedAt:    time.Now().Add(1 * time.Hour),
+						SessionID: other.ID,
+						Text:      "some question",
+						StartedAt: time.Now().Add(1 * time.Hour),
 					}
 					err = controllers.Settings.DB.Save(&question).Error
 					Expect(err).ToNot(HaveOccurred())
 				})
 
-				It("returns an HTTP Unauthorized", func() {
+				It("returns an HTTP Forbidden and doesn't record the answer", func() {
 					params := map[string]string{
 						"id":     strconv.Itoa(int(question.ID)),
 						"answer": "2",
@@ -92,23 +113,79 @@ var _ = Describe("QuestionController test", func() {
 					Expect(err).ToNot(HaveOccurred())
 
 					w, _ := performPostWithParams(router, "POST", path, params, cookie)
-					Expect(w.Code).To(Equal(http.StatusUnauthorized))
+					Expect(w.Code).To(Equal(http.StatusForbidden))
 
 					err = controllers.Settings.DB.Find(&question).Error
 					Expect(err).ToNot(HaveOccurred())
 
-					Expect(question.UserAnswer).To(Equal(2))
+					Expect(question.UserAnswer).To(Equal(0))
+					Expect(question.AnsweredAt.IsZero()).To(BeTrue())
+				})
+			})
 
+			When("the question is expired", func() {
+				BeforeEach(func() {
+					question = models.Question{
+						Text:           "some question",
+						StartedAt:      time.Now().Add(-time.Minute),
+						AllowedSeconds: 10,
+						SessionID:      session.ID,
+						RightAnswer:    2,
+					}
+					err = controllers.Settings.DB.Save(&question).Error
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				AfterEach(func() {
+					controllers.Settings.AnswerGracePeriod = 0
+				})
+
+				It("rejects the answer and records the expiry", func() {
+					params := map[string]string{"answer": "2"}
+
+					path, err := controllers.GetRoutePath("QuestionAnswer",
+						map[string]string{"id": strconv.Itoa(int(question.ID))})
+					Expect(err).ToNot(HaveOccurred())
+
+					w, _ := performPostWithParams(router, "POST", path, params, cookie)
+					Expect(w.Code).To(Equal(http.StatusFound))
+					Expect(w.Body.String()).To(ContainSubstring(html.EscapeString("Time's up!")))
+
+					err = controllers.Settings.DB.Find(&question).Error
+					Expect(err).ToNot(HaveOccurred())
+					Expect(question.UserAnswer).To(Equal(0))
+					Expect(question.ExpiredAt).To(BeTemporally("~", question.StartedAt.Add(10*time.Second), time.Second))
+
+					err = controllers.Settings.DB.Find(&session).Error
+					Expect(err).ToNot(HaveOccurred())
+					Expect(session.Complete).To(BeTrue())
+					Expect(session.Score).To(Equal(0))
+				})
+
+				It("accepts the answer within the grace period", func() {
+					controllers.Settings.AnswerGracePeriod = time.Minute
+					params := map[string]string{"answer": "2"}
+
+					path, err := controllers.GetRoutePath("QuestionAnswer",
+						map[string]string{"id": strconv.Itoa(int(question.ID))})
+					Expect(err).ToNot(HaveOccurred())
+
+					w, _ := performPostWithParams(router, "POST", path, params, cookie)
+					Expect(w.Code).To(Equal(http.StatusFound))
+
+					err = controllers.Settings.DB.Find(&question).Error
+					Expect(err).ToNot(HaveOccurred())
+					Expect(question.UserAnswer).To(Equal(2))
 				})
 			})
 
 			When("question is not expired and not answered", func() {
 				BeforeEach(func() {
 					question = models.Question{
-						Text:         "some question",
-						StartedAt:    time.Now().Add(1 * time.Hour),
-						SessionEmail: session.Email,
-						RightAnswer:  2,
+						Text:        "some question",
+						StartedAt:   time.Now().Add(1 * time.Hour),
+						SessionID:   session.ID,
+						RightAnswer: 2,
 					}
 					err = controllers.Settings.DB.Save(&question).Error
 					Expect(err).ToNot(HaveOccurred())
@@ -131,6 +208,7 @@ var _ = Describe("QuestionController test", func() {
 					Expect(err).ToNot(HaveOccurred())
 
 					Expect(question.UserAnswer).To(Equal(2))
+					Expect(question.AnsweredAt).To(BeTemporally("~", time.Now(), time.Minute))
 
 					// Reload session
 					err = controllers.Settings.DB.Find(&session).Error
@@ -140,6 +218,64 @@ var _ = Describe("QuestionController test", func() {
 					Expect(session.Complete).To(BeTrue())
 				})
 
+				When("the question is multiple-choice", func() {
+					BeforeEach(func() {
+						question.Type = models.MultipleChoice
+						question.RightAnswers = models.AnswerSet{1, 3}
+						question.Answers = models.Answers{"a1", "a2", "a3"}
+						err = controllers.Settings.DB.Save(&question).Error
+						Expect(err).ToNot(HaveOccurred())
+					})
+
+					It("stores all the selected answers", func() {
+						form := url.Values{"answer": []string{"3", "1"}}
+
+						path, err := controllers.GetRoutePath("QuestionAnswer",
+							map[string]string{"id": strconv.Itoa(int(question.ID))})
+						Expect(err).ToNot(HaveOccurred())
+
+						w, _ := performPostWithForm(router, "POST", path, form, cookie)
+						Expect(w.Code).To(Equal(http.StatusFound))
+
+						err = controllers.Settings.DB.Find(&question).Error
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(question.UserAnswers).To(ConsistOf(1, 3))
+
+						err = controllers.Settings.DB.Find(&session).Error
+						Expect(err).ToNot(HaveOccurred())
+						Expect(session.Score).To(Equal(100))
+					})
+				})
+
+				When("the question is a text question", func() {
+					BeforeEach(func() {
+						question.Type = models.TextAnswer
+						question.AcceptedAnswers = models.Answers{"etcd"}
+						err = controllers.Settings.DB.Save(&question).Error
+						Expect(err).ToNot(HaveOccurred())
+					})
+
+					It("stores the submitted text", func() {
+						params := map[string]string{"answer": " ETCD "}
+
+						path, err := controllers.GetRoutePath("QuestionAnswer",
+							map[string]string{"id": strconv.Itoa(int(question.ID))})
+						Expect(err).ToNot(HaveOccurred())
+
+						w, _ := performPostWithParams(router, "POST", path, params, cookie)
+						Expect(w.Code).To(Equal(http.StatusFound))
+
+						err = controllers.Settings.DB.Find(&question).Error
+						Expect(err).ToNot(HaveOccurred())
+						Expect(question.UserTextAnswer).To(Equal("ETCD"))
+
+						err = controllers.Settings.DB.Find(&session).Error
+						Expect(err).ToNot(HaveOccurred())
+						Expect(session.Score).To(Equal(100))
+					})
+				})
+
 				When("the answer param is empty", func() {
 					It("returns an error", func() {
 						params := map[string]string{
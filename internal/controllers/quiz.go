@@ -31,7 +31,7 @@ type (
 
 func (c *QuizController) New(gctx *gin.Context) {
 	submitURL, err := GetFullURL(gctx.Request, "QuizCreate", nil)
-	if handleError(gctx.Writer, err, http.StatusInternalServerError) {
+	if renderError(gctx, err, http.StatusInternalServerError) {
 		return
 	}
 
@@ -41,23 +41,18 @@ func (c *QuizController) New(gctx *gin.Context) {
 		SubmitURL: submitURL,
 	}
 
-	Render([]string{"main_layout", path.Join("quizzes", "new")}, gctx.Writer, viewData)
+	RenderPage(gctx, []string{"main_layout", path.Join("quizzes", "new")}, viewData)
 }
 
 func (c *QuizController) Show(gctx *gin.Context) {
 	currentSession, err := currentSession(gctx)
-	if handleError(gctx.Writer, err, http.StatusBadRequest) {
-		return
-	}
-
-	err = Settings.DB.Preload(clause.Associations).Find(&currentSession).Error
-	if handleError(gctx.Writer, err, http.StatusInternalServerError) {
+	if err != nil {
+		redirectWithError(gctx, err, "QuizNew")
 		return
 	}
 
-	currentQuestion, err := currentSession.CurrentQuestion()
-	// TODO: Return a flash error (when flashes are implemented)
-	if handleError(gctx.Writer, err, http.StatusInternalServerError) {
+	currentQuestion, err := restartOrCurrentQuestion(&currentSession)
+	if renderError(gctx, err, http.StatusInternalServerError) {
 		return
 	}
 
@@ -72,25 +67,16 @@ func (c *QuizController) Show(gctx *gin.Context) {
 			Session:         currentSession,
 			ScorePercentage: strconv.Itoa(score),
 		}
-		Render([]string{"main_layout", path.Join("quizzes", "result")}, gctx.Writer, viewData)
+		RenderPage(gctx, []string{"main_layout", path.Join("quizzes", "result")}, viewData)
 		return
 	}
 
 	questionID := strconv.Itoa(int(currentQuestion.ID))
 	submitURL, err := GetFullURL(gctx.Request, "QuestionAnswer", map[string]string{"id": questionID})
-	if handleError(gctx.Writer, err, http.StatusInternalServerError) {
+	if renderError(gctx, err, http.StatusInternalServerError) {
 		return
 	}
 
-	// If it's the first time we show the question, make it "started"
-	if currentQuestion.StartedAt.IsZero() {
-		currentQuestion.StartedAt = time.Now()
-		err = Settings.DB.Save(&currentQuestion).Error
-		if handleError(gctx.Writer, err, http.StatusInternalServerError) {
-			return
-		}
-	}
-
 	endTime := currentQuestion.StartedAt.Add(
 		time.Duration(currentQuestion.AllowedSeconds) * time.Second)
 	timeLeft := int(time.Until(endTime).Seconds())
@@ -108,34 +94,35 @@ func (c *QuizController) Show(gctx *gin.Context) {
 		TotalQuestions:  len(currentSession.Questions),
 	}
 
-	Render([]string{"main_layout", path.Join("quizzes", "show")}, gctx.Writer, viewData)
+	RenderPage(gctx, []string{"main_layout", path.Join("quizzes", "show")}, viewData)
 }
 
 func (c *QuizController) Create(gctx *gin.Context) {
 	err := gctx.Request.ParseForm()
-	if handleError(gctx.Writer, err, http.StatusBadRequest) {
+	if renderError(gctx, err, http.StatusBadRequest) {
 		return
 	}
 
 	submittedEmail := gctx.Request.FormValue("email")
 	if !models.ValidEmail(submittedEmail) {
-		handleError(gctx.Writer, errors.New("invalid email"), http.StatusBadRequest)
+		redirectWithError(gctx, errInvalidEmail, "QuizNew")
 		return
 	}
 
 	session, err := ensureQuizSession(gctx)
-	if handleError(gctx.Writer, err, http.StatusBadRequest) {
+	if err != nil {
+		redirectWithError(gctx, err, "QuizNew")
 		return
 	}
 
 	redirectURL, err := GetFullURL(gctx.Request, "QuizShow", nil)
-	if handleError(gctx.Writer, err, http.StatusInternalServerError) {
+	if renderError(gctx, err, http.StatusInternalServerError) {
 		return
 	}
 
 	// Reload the session with Questions
 	err = Settings.DB.Preload(clause.Associations).Find(&session).Error
-	if handleError(gctx.Writer, err, http.StatusBadRequest) {
+	if renderError(gctx, err, http.StatusBadRequest) {
 		return
 	}
 	if len(session.Questions) > 0 { // Pre-existing session, just redirect to quiz
@@ -143,30 +130,75 @@ func (c *QuizController) Create(gctx *gin.Context) {
 		return
 	}
 
-	qp, err := models.NewQuestionPoolFromFile(Settings.QuestionPoolFile)
-	if handleError(gctx.Writer, err, http.StatusInternalServerError) {
+	if renderError(gctx, startQuiz(session), http.StatusInternalServerError) {
 		return
 	}
 
-	// TODO: Don't hardcode
-	q, err := models.NewQuizWithOpts(models.QuizOptions{
-		TotalQuestions:     15,
-		MinDifficulty:      1,
-		MaxDifficulty:      10,
-		QuestionTimeoutSec: 30,
-		AvailableQuestions: qp.Questions,
-	})
+	gctx.Redirect(http.StatusFound, redirectURL)
+}
+
+// startQuiz picks the questions of a new session and pushes the leaderboard
+// (with a new session in progress)
+func startQuiz(session models.Session) error {
+	qp, err := currentQuestionPool()
+	if err != nil {
+		return err
+	}
 
-	if handleError(gctx.Writer, err, http.StatusInternalServerError) {
-		return
+	q, err := models.NewQuizWithOpts(models.QuizOptionsFor(qp, Settings.QuizOverrides))
+	if err != nil {
+		return err
 	}
 
-	err = q.PersistForSessionEmail(Settings.DB, session.Email)
-	if handleError(gctx.Writer, err, http.StatusInternalServerError) {
-		return
+	if err := q.PersistForSessionEmail(Settings.DB, session.Email); err != nil {
+		return err
 	}
+	NotifyLeaderboardChanged()
 
-	gctx.Redirect(http.StatusFound, redirectURL)
+	return nil
+}
+
+// restartOrCurrentQuestion is like startCurrentQuestion but, when the session
+// has no questions because an admin reset it, it starts over with new ones.
+func restartOrCurrentQuestion(session *models.Session) (models.Question, error) {
+	question, err := startCurrentQuestion(session)
+	if err != nil || len(session.Questions) > 0 {
+		return question, err
+	}
+	if err := startQuiz(*session); err != nil {
+		return question, err
+	}
+
+	return startCurrentQuestion(session)
+}
+
+// startCurrentQuestion loads the questions of the session, records the ones
+// that ran out of time (so that they are not shown again and the score is up
+// to date) and returns the question to show. The question is marked as
+// started the first time. A zero question means the quiz is finished.
+func startCurrentQuestion(session *models.Session) (models.Question, error) {
+	expired, _, err := session.Refresh(Settings.DB, time.Now(), 0)
+	if err != nil {
+		return models.Question{}, err
+	}
+	if expired > 0 {
+		NotifyLeaderboardChanged()
+	}
+
+	question, err := session.CurrentQuestion()
+	if err != nil || question.ID == 0 {
+		return question, err
+	}
+
+	// If it's the first time we show the question, make it "started"
+	if question.StartedAt.IsZero() {
+		question.StartedAt = time.Now()
+		if err := Settings.DB.Model(&question).Update("started_at", question.StartedAt).Error; err != nil {
+			return question, err
+		}
+	}
+
+	return question, nil
 }
 
 func ensureQuizSession(ctx *gin.Context) (models.Session, error) {
@@ -179,7 +211,7 @@ func ensureQuizSession(ctx *gin.Context) (models.Session, error) {
 	if errors.Is(err, http.ErrNoCookie) { // no cookie found
 		session, err = models.SessionForEmail(Settings.DB, submittedEmail)
 		if err == nil {
-			return session, errors.New("email has already been used previously")
+			return session, errEmailUsed
 		}
 
 		return newSession(ctx, submittedEmail, submittedNickname) // fresh email
@@ -190,7 +222,7 @@ func ensureQuizSession(ctx *gin.Context) (models.Session, error) {
 
 	// valid cookie with email. Let's lookup the session.
 	if cookieValue.Email != submittedEmail {
-		return session, fmt.Errorf("already started with email: %s", cookieValue.Email)
+		return session, fmt.Errorf("%w: %s", errOtherEmail, cookieValue.Email)
 	}
 
 	// valid cookie with email. Let's lookup the session.
@@ -198,7 +230,11 @@ func ensureQuizSession(ctx *gin.Context) (models.Session, error) {
 	// User has a valid cookie but we can't find a session.
 	// Create a new one (we probably deleted the session from db).
 	if err != nil {
-		return newSession(ctx, cookieValue.Email, submittedNickname)
+		session, err = newSession(ctx, cookieValue.Email, submittedNickname)
+		if errors.Is(err, errEmailUsed) { // created by a concurrent request of the same user
+			return models.SessionForEmail(Settings.DB, cookieValue.Email)
+		}
+		return session, err
 	}
 
 	return session, nil
@@ -207,11 +243,11 @@ func ensureQuizSession(ctx *gin.Context) (models.Session, error) {
 func validTimestamp(timestampStr string) error {
 	timestamp, err := time.Parse(COOKIE_TIMESTAMP_FORMAT, timestampStr)
 	if err != nil {
-		return errors.New("invalid timestamp")
+		return fmt.Errorf("%w: invalid timestamp", errSessionExpired)
 	}
 
 	if time.Since(timestamp).Seconds() > COOKIE_LIFETIME_SEC {
-		return errors.New("cookie has expired")
+		return fmt.Errorf("%w: cookie has expired", errSessionExpired)
 	}
 
 	return nil
@@ -222,6 +258,9 @@ func newSession(ctx *gin.Context, email, nickname string) (models.Session, error
 	var result models.Session
 
 	result, err = models.NewSession(Settings.DB, email, nickname)
+	if errors.Is(err, models.ErrEmailUsed) { // someone else got there first
+		return result, errEmailUsed
+	}
 	if err != nil {
 		return result, fmt.Errorf("creating a new session: %w", err)
 	}
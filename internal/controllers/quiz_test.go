@@ -5,12 +5,14 @@ import (
 	"net/http/httptest"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jimmykarily/quizmaker/internal/controllers"
 	"github.com/jimmykarily/quizmaker/internal/models"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
@@ -49,6 +51,71 @@ var _ = Describe("QuizController test", func() {
 		})
 	})
 
+	Describe("#Show", func() {
+		When("the quiz is complete", func() {
+			It("escapes the answers of the participant", func() {
+				email := "john.doe@example.com"
+				cookie, err := controllers.CreateCookie(email, "Firefox")
+				Expect(err).ToNot(HaveOccurred())
+				session := models.Session{Email: email}
+				Expect(controllers.Settings.DB.Create(&session).Error).ToNot(HaveOccurred())
+				question := models.Question{
+					SessionID: session.ID, Index: 1, Text: "Which component talks to the container runtime?",
+					Type: "text", AcceptedAnswers: models.Answers{"kubelet"}, AllowedSeconds: 30,
+					UserTextAnswer: "<script>alert(1)</script>",
+					StartedAt:      time.Now().Add(-10 * time.Second), AnsweredAt: time.Now().Add(-5 * time.Second),
+				}
+				Expect(controllers.Settings.DB.Create(&question).Error).ToNot(HaveOccurred())
+
+				route, err := controllers.RouteByName("QuizShow")
+				Expect(err).ToNot(HaveOccurred())
+				req, err := http.NewRequest("GET", route.Path, nil)
+				Expect(err).ToNot(HaveOccurred())
+				req.AddCookie(cookie)
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusOK), w.Body.String())
+				Expect(w.Body.String()).To(ContainSubstring("You answered:"))
+				Expect(w.Body.String()).To(ContainSubstring("&lt;script&gt;alert(1)&lt;/script&gt;"))
+				Expect(w.Body.String()).ToNot(ContainSubstring("<script>alert(1)"))
+			})
+		})
+
+		When("the session was reset by an admin", func() {
+			var cookie *http.Cookie
+
+			BeforeEach(func() {
+				email := "john.doe@example.com"
+				w, cookie = performQuizCreateRequest(router, email, nil)
+				Expect(w.Body.String()).To(MatchRegexp("Question.*with difficulty"))
+
+				session, err := models.SessionForEmail(controllers.Settings.DB, email)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(session.Reset(controllers.Settings.DB)).To(Succeed())
+			})
+
+			It("starts a new quiz", func() {
+				route, err := controllers.RouteByName("QuizShow")
+				Expect(err).ToNot(HaveOccurred())
+				req, err := http.NewRequest("GET", route.Path, nil)
+				Expect(err).ToNot(HaveOccurred())
+				req.AddCookie(cookie)
+
+				w = httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusOK), w.Body.String())
+				Expect(w.Body.String()).To(MatchRegexp("Question.*with difficulty"))
+
+				var session models.Session
+				err = controllers.Settings.DB.Preload(clause.Associations).First(&session).Error
+				Expect(err).ToNot(HaveOccurred())
+				Expect(session.Questions).To(HaveLen(15))
+				Expect(session.Complete).To(BeFalse())
+			})
+		})
+	})
+
 	Describe("#Create", func() {
 		var email string
 		var session models.Session
@@ -59,6 +126,55 @@ var _ = Describe("QuizController test", func() {
 			w, cookie = performQuizCreateRequest(router, email, nil)
 		})
 
+		When("the email is invalid", func() {
+			BeforeEach(func() {
+				w, _ = performQuizCreateRequest(router, "not-an-email", nil)
+			})
+
+			It("shows the form again with an error", func() {
+				Expect(w.Code).To(Equal(http.StatusFound))
+				Expect(w.Body.String()).To(ContainSubstring("Please enter a valid email address."))
+				Expect(w.Body.String()).To(MatchRegexp("Start Quiz"))
+			})
+		})
+
+		When("the email was used without the cookie", func() {
+			BeforeEach(func() {
+				w, _ = performQuizCreateRequest(router, email, nil)
+			})
+
+			It("shows the form again with an error", func() {
+				Expect(w.Body.String()).To(ContainSubstring("This email has already been used to take the quiz."))
+			})
+		})
+
+		When("a concurrent request creates a session with the same email first", func() {
+			BeforeEach(func() {
+				// insert the other session (outside of the transaction of this
+				// one) right before this one
+				db := controllers.Settings.DB
+				inserted := false
+				err := db.Callback().Create().Before("gorm:create").Register("concurrent_session", func(tx *gorm.DB) {
+					if _, ok := tx.Statement.Dest.(*models.Session); !ok || inserted {
+						return
+					}
+					inserted = true
+					Expect(db.Exec("INSERT INTO sessions (email, created_at) VALUES (?, ?)", "jane.doe@example.com", time.Now()).Error).To(Succeed())
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				w, _ = performQuizCreateRequest(router, "jane.doe@example.com", nil)
+			})
+
+			It("shows the form again with an error", func() {
+				Expect(w.Body.String()).To(ContainSubstring("This email has already been used to take the quiz."))
+
+				var count int64
+				Expect(controllers.Settings.DB.Model(&models.Session{}).Where("email = ?", "jane.doe@example.com").Count(&count).Error).To(Succeed())
+				Expect(count).To(BeEquivalentTo(1))
+			})
+		})
+
 		When("a quiz doesn't exist", func() {
 			It("creates a new quiz", func() {
 				err := controllers.Settings.DB.Preload(clause.Associations).First(&session).Error
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/jimmykarily/quizmaker/internal/models"
+)
+
+// RelayChange pushes a change made on another replica of the server (see
+// models.NotificationRelay) to the browsers connected to this one. The events
+// carry the whole current state, so pushing a change twice does no harm.
+func RelayChange(topic string) {
+	switch {
+	case topic == LEADERBOARD_TOPIC:
+		publishLeaderboard()
+	case strings.HasPrefix(topic, GAME_TOPIC_PREFIX):
+		publishGame(strings.TrimPrefix(topic, GAME_TOPIC_PREFIX))
+	}
+}
+
+// notifyReplicas records the change of the topic for the other replicas when
+// Settings.NotifyReplicas is set
+func notifyReplicas(topic string) {
+	if !Settings.NotifyReplicas {
+		return
+	}
+
+	if err := models.NotifyChange(Settings.DB, topic); err != nil && Settings.ErrorLogger != nil {
+		Settings.ErrorLogger.Println(err.Error())
+	}
+}
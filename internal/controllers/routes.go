@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jimmykarily/quizmaker/internal/models"
 )
 
 // Route describes a route for httprouter
@@ -16,6 +17,12 @@ type Route struct {
 	Path    string
 	Format  string
 	Handler gin.HandlerFunc
+	// Middlewares run before the Handler, in order (e.g. requireAdmin). One of
+	// them can stop the request with gctx.Abort().
+	Middlewares []gin.HandlerFunc
+	// Doc describes the route in the OpenAPI document. Routes without it are
+	// left out (e.g. the HTML ones).
+	Doc *APIDoc
 }
 
 type Routes []Route
@@ -29,6 +36,13 @@ func GetRoutes() Routes {
 			Format:  "html",
 			Handler: (&SessionController{}).List,
 		},
+		Route{
+			Name:    "SessionEvents",
+			Method:  "GET",
+			Path:    "/leaderboard/events",
+			Format:  "event-stream",
+			Handler: (&SessionController{}).Events,
+		},
 		Route{
 			Name:    "QuizNew",
 			Method:  "GET",
@@ -57,6 +71,254 @@ func GetRoutes() Routes {
 			Format:  "html",
 			Handler: (&QuestionController{}).Answer,
 		},
+		Route{
+			Name:    "GameNew",
+			Method:  "GET",
+			Path:    "/games/new",
+			Format:  "html",
+			Handler: (&GameController{}).New,
+		},
+		Route{
+			Name:    "GameCreate",
+			Method:  "POST",
+			Path:    "/games",
+			Format:  "html",
+			Handler: (&GameController{}).Create,
+		},
+		Route{
+			Name:    "GameJoin",
+			Method:  "GET",
+			Path:    "/games/:code",
+			Format:  "html",
+			Handler: (&GameController{}).Join,
+		},
+		Route{
+			Name:    "GamePlayerCreate",
+			Method:  "POST",
+			Path:    "/games/:code/players",
+			Format:  "html",
+			Handler: (&GameController{}).CreatePlayer,
+		},
+		Route{
+			Name:    "GamePlay",
+			Method:  "GET",
+			Path:    "/games/:code/play",
+			Format:  "html",
+			Handler: (&GameController{}).Play,
+		},
+		Route{
+			Name:    "GameAnswer",
+			Method:  "POST",
+			Path:    "/games/:code/answers",
+			Format:  "html",
+			Handler: (&GameController{}).Answer,
+		},
+		Route{
+			Name:    "GameHost",
+			Method:  "GET",
+			Path:    "/games/:code/host",
+			Format:  "html",
+			Handler: (&GameController{}).Host,
+		},
+		Route{
+			Name:    "GameNext",
+			Method:  "POST",
+			Path:    "/games/:code/next",
+			Format:  "html",
+			Handler: (&GameController{}).Next,
+		},
+		Route{
+			Name:    "GameReveal",
+			Method:  "POST",
+			Path:    "/games/:code/reveal",
+			Format:  "html",
+			Handler: (&GameController{}).Reveal,
+		},
+		Route{
+			Name:    "GameEvents",
+			Method:  "GET",
+			Path:    "/games/:code/events",
+			Format:  "event-stream",
+			Handler: (&GameController{}).Events,
+		},
+		Route{
+			Name:    "OpenAPI",
+			Method:  "GET",
+			Path:    "/openapi.json",
+			Format:  "json",
+			Handler: ShowOpenAPI,
+		},
+		Route{
+			Name:    "APISessionCreate",
+			Method:  "POST",
+			Path:    "/api/v1/sessions",
+			Format:  "json",
+			Handler: (&APIController{}).CreateSession,
+			Doc: &APIDoc{
+				Summary:   "Start a quiz and get the token of its session",
+				Request:   apiSessionRequest{},
+				Responses: map[int]interface{}{http.StatusCreated: apiSessionCreated{}},
+			},
+		},
+		Route{
+			Name:    "APISessionShow",
+			Method:  "GET",
+			Path:    "/api/v1/session",
+			Format:  "json",
+			Handler: (&APIController{}).ShowSession,
+			Doc: &APIDoc{
+				Summary:   "Get the session and, once the quiz is complete, the results",
+				Auth:      AuthSession,
+				Responses: map[int]interface{}{http.StatusOK: apiSessionResponse{}},
+			},
+		},
+		Route{
+			Name:    "APIQuestionShow",
+			Method:  "GET",
+			Path:    "/api/v1/session/question",
+			Format:  "json",
+			Handler: (&APIController{}).ShowQuestion,
+			Doc: &APIDoc{
+				Summary:   "Get the current question and start its timer",
+				Auth:      AuthSession,
+				Responses: map[int]interface{}{http.StatusOK: apiQuestionResponse{}},
+			},
+		},
+		Route{
+			Name:    "APIQuestionAnswer",
+			Method:  "POST",
+			Path:    "/api/v1/questions/:id/answer",
+			Format:  "json",
+			Handler: (&APIController{}).Answer,
+			Doc: &APIDoc{
+				Summary:   "Answer a question of the session",
+				Auth:      AuthSession,
+				Request:   apiAnswerRequest{},
+				Responses: map[int]interface{}{http.StatusOK: apiAnswerResponse{}},
+			},
+		},
+		Route{
+			Name:    "APILeaderboard",
+			Method:  "GET",
+			Path:    "/api/v1/leaderboard",
+			Format:  "json",
+			Handler: (&APIController{}).Leaderboard,
+			Doc: &APIDoc{
+				Summary:   "Get the leaderboard, with obfuscated emails",
+				Responses: map[int]interface{}{http.StatusOK: leaderboardEvent{}},
+			},
+		},
+		Route{
+			Name:        "AdminSessionList",
+			Method:      "GET",
+			Path:        "/admin",
+			Format:      "html",
+			Handler:     (&AdminController{}).List,
+			Middlewares: []gin.HandlerFunc{requireAdmin},
+		},
+		Route{
+			Name:        "AdminSessionShow",
+			Method:      "GET",
+			Path:        "/admin/sessions/:id",
+			Format:      "html",
+			Handler:     (&AdminController{}).Show,
+			Middlewares: []gin.HandlerFunc{requireAdmin},
+		},
+		Route{
+			Name:        "AdminSessionReset",
+			Method:      "POST",
+			Path:        "/admin/sessions/:id/reset",
+			Format:      "html",
+			Handler:     (&AdminController{}).Reset,
+			Middlewares: []gin.HandlerFunc{requireAdmin},
+		},
+		Route{
+			Name:        "AdminSessionDelete",
+			Method:      "POST",
+			Path:        "/admin/sessions/:id/delete",
+			Format:      "html",
+			Handler:     (&AdminController{}).Delete,
+			Middlewares: []gin.HandlerFunc{requireAdmin},
+		},
+		Route{
+			Name:        "AdminSessionDisqualify",
+			Method:      "POST",
+			Path:        "/admin/sessions/:id/disqualify",
+			Format:      "html",
+			Handler:     (&AdminController{}).Disqualify,
+			Middlewares: []gin.HandlerFunc{requireAdmin},
+		},
+		Route{
+			Name:        "AdminQuestionList",
+			Method:      "GET",
+			Path:        "/admin/questions",
+			Format:      "html",
+			Handler:     (&AdminController{}).Questions,
+			Middlewares: []gin.HandlerFunc{requireAdmin},
+		},
+		Route{
+			Name:        "AdminAnalytics",
+			Method:      "GET",
+			Path:        "/admin/analytics",
+			Format:      "html",
+			Handler:     (&AdminController{}).Analytics,
+			Middlewares: []gin.HandlerFunc{requireAdmin},
+		},
+		Route{
+			Name:        "AdminExportCSV",
+			Method:      "GET",
+			Path:        "/admin/export.csv",
+			Format:      "csv",
+			Handler:     (&AdminController{}).ExportCSV,
+			Middlewares: []gin.HandlerFunc{requireAdmin},
+			Doc: &APIDoc{
+				Summary:   "Export the sessions and their answers as CSV",
+				Auth:      AuthAdmin,
+				Query:     []string{"from", "to", "state"},
+				Responses: map[int]interface{}{http.StatusOK: nil},
+			},
+		},
+		Route{
+			Name:        "AdminExportJSON",
+			Method:      "GET",
+			Path:        "/admin/export.json",
+			Format:      "json",
+			Handler:     (&AdminController{}).ExportJSON,
+			Middlewares: []gin.HandlerFunc{requireAdmin},
+			Doc: &APIDoc{
+				Summary:   "Export the sessions and their answers as JSON",
+				Auth:      AuthAdmin,
+				Query:     []string{"from", "to", "state"},
+				Responses: map[int]interface{}{http.StatusOK: []models.ExportedSession{}},
+			},
+		},
+		Route{
+			Name:        "AdminRegrade",
+			Method:      "POST",
+			Path:        "/admin/regrade",
+			Format:      "json",
+			Handler:     (&AdminController{}).Regrade,
+			Middlewares: []gin.HandlerFunc{requireAdmin},
+			Doc: &APIDoc{
+				Summary:   "Regrade the sessions with the answer key of the question pool",
+				Auth:      AuthAdmin,
+				Responses: map[int]interface{}{http.StatusOK: regradeResponse{}},
+			},
+		},
+		Route{
+			Name:        "AdminQuestionVoid",
+			Method:      "POST",
+			Path:        "/admin/questions/:id/void",
+			Format:      "json",
+			Handler:     (&AdminController{}).Void,
+			Middlewares: []gin.HandlerFunc{requireAdmin},
+			Doc: &APIDoc{
+				Summary:   "Void (or restore) a question for all sessions",
+				Auth:      AuthAdmin,
+				Form:      []string{"voided"},
+				Responses: map[int]interface{}{http.StatusOK: regradeResponse{}},
+			},
+		},
 	}
 
 	return routes
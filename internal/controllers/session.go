@@ -2,66 +2,230 @@ package controllers
 
 import (
 	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"path"
-	"sort"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jimmykarily/quizmaker/internal/events"
 	"github.com/jimmykarily/quizmaker/internal/models"
 )
 
+const (
+	LEADERBOARD_EVENT         = "leaderboard"
+	LEADERBOARD_TOPIC         = "leaderboard" // see RelayChange
+	EVENTS_KEEPALIVE_INTERVAL = 30 * time.Second
+)
+
 type (
 	SessionController struct{}
-)
 
-func (c *SessionController) List(gctx *gin.Context) {
-	sessions := []models.Session{}
-	err := Settings.DB.Find(&sessions).Error
-	if handleError(gctx.Writer, err, http.StatusInternalServerError) {
-		return
+	// rankedSession is a completed session along with the prize it wins
+	rankedSession struct {
+		models.Session
+		Prize models.Prize
 	}
 
-	var complete, inProgress []models.Session
-	for _, s := range sessions {
-		if s.Complete {
-			complete = append(complete, s)
-		} else {
-			inProgress = append(inProgress, s)
-		}
+	// leaderboardRow is what the live leaderboard shows for every session
+	leaderboardRow struct {
+		Rank     int    `json:"rank,omitempty"`
+		Nickname string `json:"nickname"`
+		Email    string `json:"email"` // obfuscated
+		Score    string `json:"score"`
+		Prize    string `json:"prize,omitempty"`
+	}
+
+	leaderboardEvent struct {
+		Completed  []leaderboardRow `json:"completed"`
+		InProgress []leaderboardRow `json:"inProgress"`
 	}
+)
 
-	sort.Slice(complete, func(i, j int) bool {
-		return complete[i].Score > complete[j].Score
-	})
+func (c *SessionController) List(gctx *gin.Context) {
+	completed, inProgress, prizes, err := currentLeaderboard()
+	if renderError(gctx, err, http.StatusInternalServerError) {
+		return
+	}
 
 	NewQuizURL, err := GetFullURL(gctx.Request, "QuizNew", nil)
-	if handleError(gctx.Writer, err, http.StatusInternalServerError) {
+	if renderError(gctx, err, http.StatusInternalServerError) {
 		return
 	}
 
 	png, err := getQRCodePNG(NewQuizURL)
-	if handleError(gctx.Writer, err, http.StatusInternalServerError) {
+	if renderError(gctx, err, http.StatusInternalServerError) {
 		return
 	}
 
-	qp, err := models.NewQuestionPoolFromFile(Settings.QuestionPoolFile)
-	if handleError(gctx.Writer, err, http.StatusInternalServerError) {
+	eventsPath, err := GetRoutePath("SessionEvents", nil)
+	if renderError(gctx, err, http.StatusInternalServerError) {
 		return
 	}
 
 	viewData := struct {
 		QRCodePNG  string
 		NewQuizURL string
-		Completed  []models.Session
+		EventsPath string
+		Completed  []rankedSession
 		InProgress []models.Session
 		Prizes     models.PrizeList
 	}{
 		QRCodePNG:  base64.StdEncoding.EncodeToString(png),
 		NewQuizURL: NewQuizURL,
-		Completed:  complete,
+		EventsPath: eventsPath,
+		Completed:  completed,
 		InProgress: inProgress,
-		Prizes:     qp.Prizes,
+		Prizes:     prizes,
+	}
+
+	RenderPage(gctx, []string{"main_layout", path.Join("sessions", "list")}, viewData)
+}
+
+// Events streams the leaderboard as server-sent events: the current one right
+// away and a new one every time it changes (see NotifyLeaderboardChanged).
+func (c *SessionController) Events(gctx *gin.Context) {
+	if Settings.Events == nil {
+		handleError(gctx.Writer, errors.New("live updates are disabled"), http.StatusServiceUnavailable)
+		return
+	}
+
+	// subscribe first so that no change is missed while sending the current state
+	stream, unsubscribe := Settings.Events.Subscribe()
+	defer unsubscribe()
+
+	current, err := leaderboardEventData()
+	if handleError(gctx.Writer, err, http.StatusInternalServerError) {
+		return
+	}
+
+	streamEvents(gctx, events.Event{Name: LEADERBOARD_EVENT, Data: current}, stream)
+}
+
+// streamEvents writes the current event and then the ones of the stream as
+// server-sent events, until the client goes away or the stream is closed.
+func streamEvents(gctx *gin.Context, current events.Event, stream <-chan events.Event) {
+	gctx.Header("Cache-Control", "no-cache")
+	gctx.Header("X-Accel-Buffering", "no") // don't let proxies buffer the stream
+	gctx.SSEvent(current.Name, string(current.Data))
+	gctx.Writer.Flush()
+
+	keepalive := time.NewTicker(EVENTS_KEEPALIVE_INTERVAL)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-gctx.Request.Context().Done():
+			return
+		case e, ok := <-stream:
+			if !ok { // shutting down
+				return
+			}
+			gctx.SSEvent(e.Name, string(e.Data))
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(gctx.Writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		gctx.Writer.Flush()
+	}
+}
+
+// NotifyLeaderboardChanged pushes the current leaderboard to the live
+// leaderboards. It's called whenever a session is saved.
+func NotifyLeaderboardChanged() {
+	notifyReplicas(LEADERBOARD_TOPIC)
+	publishLeaderboard()
+}
+
+// publishLeaderboard pushes the current leaderboard to the live leaderboards
+// of this replica
+func publishLeaderboard() {
+	if Settings.Events == nil || Settings.Events.Subscribers() == 0 {
+		return
+	}
+
+	data, err := leaderboardEventData()
+	if err != nil {
+		if Settings.ErrorLogger != nil { // we don't set it in tests
+			Settings.ErrorLogger.Printf("pushing the leaderboard: %s\n", err.Error())
+		}
+		return
+	}
+
+	Settings.Events.Publish(events.Event{Name: LEADERBOARD_EVENT, Data: data})
+}
+
+// currentLeaderboard returns the ranked completed sessions (with the prize
+// they win), the sessions in progress and the prizes. Disqualified sessions
+// are left out.
+func currentLeaderboard() ([]rankedSession, []models.Session, models.PrizeList, error) {
+	sessions := []models.Session{}
+	if err := Settings.DB.Where("disqualified = ?", false).Find(&sessions).Error; err != nil {
+		return nil, nil, nil, err
+	}
+
+	var complete, inProgress []models.Session
+	for _, s := range sessions {
+		if s.Complete {
+			complete = append(complete, s)
+		} else {
+			inProgress = append(inProgress, s)
+		}
+	}
+
+	complete = models.RankSessions(complete)
+
+	qp, err := currentQuestionPool()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	completed := []rankedSession{}
+	for _, s := range complete {
+		prize, _ := qp.Prizes.ForRank(s.Rank)
+		completed = append(completed, rankedSession{Session: s, Prize: prize})
+	}
+
+	return completed, inProgress, qp.Prizes, nil
+}
+
+func leaderboardEventData() ([]byte, error) {
+	event, err := currentLeaderboardEvent()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(event)
+}
+
+// currentLeaderboardEvent returns the leaderboard with obfuscated emails, as
+// it's pushed to the live leaderboards (and served by the API)
+func currentLeaderboardEvent() (leaderboardEvent, error) {
+	completed, inProgress, _, err := currentLeaderboard()
+	if err != nil {
+		return leaderboardEvent{}, err
+	}
+
+	event := leaderboardEvent{Completed: []leaderboardRow{}, InProgress: []leaderboardRow{}}
+	for _, s := range completed {
+		event.Completed = append(event.Completed, leaderboardRow{
+			Rank:     s.Rank,
+			Nickname: s.Nickname,
+			Email:    s.EmailObfuscated(),
+			Score:    s.ScoreLabel(),
+			Prize:    s.Prize.Title,
+		})
+	}
+	for _, s := range inProgress {
+		event.InProgress = append(event.InProgress, leaderboardRow{
+			Nickname: s.Nickname,
+			Email:    s.EmailObfuscated(),
+			Score:    s.ScoreLabel(),
+		})
 	}
 
-	Render([]string{"main_layout", path.Join("sessions", "list")}, gctx.Writer, viewData)
+	return event, nil
 }
@@ -0,0 +1,112 @@
+package controllers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jimmykarily/quizmaker/internal/controllers"
+	"github.com/jimmykarily/quizmaker/internal/events"
+	"github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SessionController test", func() {
+	var router *gin.Engine
+	var w *httptest.ResponseRecorder
+
+	BeforeEach(func() {
+		router = gin.Default()
+		controllers.SetupRoutes(router, controllers.GetRoutes())
+
+		w = httptest.NewRecorder()
+
+		controllers.Settings.QuestionPoolFile =
+			filepath.Join(currentDir, "tests/assets/question_pool.yaml")
+		controllers.Settings.Events = events.NewBroker()
+	})
+
+	AfterEach(func() {
+		controllers.Settings.Events = nil
+	})
+
+	Describe("#Events", func() {
+		It("streams the current leaderboard and every change", func() {
+			session := models.Session{Email: "alice@example.com", Nickname: "alice"}
+			Expect(controllers.Settings.DB.Create(&session).Error).ToNot(HaveOccurred())
+
+			path, err := controllers.GetRoutePath("SessionEvents", nil)
+			Expect(err).ToNot(HaveOccurred())
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, "GET", path, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				router.ServeHTTP(w, req)
+				close(done)
+			}()
+			Eventually(controllers.Settings.Events.Subscribers).Should(Equal(1))
+
+			session.Complete = true
+			session.Score = 80
+			Expect(controllers.Settings.DB.Save(&session).Error).ToNot(HaveOccurred())
+			controllers.NotifyLeaderboardChanged()
+
+			cancel()
+			Eventually(done).Should(BeClosed())
+			Expect(controllers.Settings.Events.Subscribers()).To(BeZero())
+
+			Expect(w.Header().Get("Content-Type")).To(ContainSubstring("text/event-stream"))
+			body := w.Body.String()
+			Expect(body).To(ContainSubstring(`event:leaderboard`))
+			Expect(body).To(ContainSubstring(`"completed":[],"inProgress":[{"nickname":"alice"`))
+			Expect(body).To(ContainSubstring(`"completed":[{"rank":1,"nickname":"alice","email":"a...e@e.....e.com","score":"80%"`))
+		})
+
+		It("ends the stream when the broker is closed", func() {
+			path, err := controllers.GetRoutePath("SessionEvents", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req, err := http.NewRequest("GET", path, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				router.ServeHTTP(w, req)
+				close(done)
+			}()
+			Eventually(controllers.Settings.Events.Subscribers).Should(Equal(1))
+
+			controllers.Settings.Events.Close()
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
+	Describe("with several replicas", func() {
+		BeforeEach(func() {
+			controllers.Settings.NotifyReplicas = true
+		})
+
+		AfterEach(func() {
+			controllers.Settings.NotifyReplicas = false
+		})
+
+		It("records the changes for the other replicas and pushes theirs", func() {
+			controllers.NotifyLeaderboardChanged()
+			versions, err := models.ChangeVersions(controllers.Settings.DB)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(versions).To(HaveKeyWithValue(controllers.LEADERBOARD_TOPIC, int64(1)))
+
+			stream, unsubscribe := controllers.Settings.Events.Subscribe()
+			defer unsubscribe()
+			controllers.RelayChange(controllers.LEADERBOARD_TOPIC)
+			Eventually(stream).Should(Receive(HaveField("Name", controllers.LEADERBOARD_EVENT)))
+		})
+	})
+})
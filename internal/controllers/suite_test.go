@@ -17,8 +17,6 @@ import (
 	"github.com/jimmykarily/quizmaker/internal/models"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"gorm.io/driver/sqlite"
-	"gorm.io/gorm"
 )
 
 func TestConfig(t *testing.T) {
@@ -30,16 +28,24 @@ var originalWorkingDir string
 var currentDir string
 
 var _ = BeforeEach(func() {
-	// reset the db before each test
-	testDbPath, err := filepath.Abs(filepath.Join("..", "..", "tests", "database.sql"))
-	Expect(err).ToNot(HaveOccurred())
-	err = os.RemoveAll(testDbPath)
-	Expect(err).ToNot(HaveOccurred())
+	// reset the db before each test. Set QUIZMAKER_TEST_DATABASE_URL to run
+	// the tests against another database, all its tables are dropped.
+	databaseURL := os.Getenv("QUIZMAKER_TEST_DATABASE_URL")
+	if databaseURL == "" {
+		testDbPath, err := filepath.Abs(filepath.Join("..", "..", "tests", "database.sql"))
+		Expect(err).ToNot(HaveOccurred())
+		err = os.RemoveAll(testDbPath)
+		Expect(err).ToNot(HaveOccurred())
+		databaseURL = testDbPath
+	}
 
-	controllers.Settings.DB, err = gorm.Open(sqlite.Open(testDbPath), &gorm.Config{})
+	var err error
+	controllers.Settings.DB, err = models.OpenDatabase(databaseURL)
 	Expect(err).ToNot(HaveOccurred())
 
-	err = models.AutoMigrate(controllers.Settings.DB)
+	err = models.DropTables(controllers.Settings.DB)
+	Expect(err).ToNot(HaveOccurred())
+	_, err = models.MigrateUp(controllers.Settings.DB)
 	Expect(err).ToNot(HaveOccurred())
 
 	// Change working directory because ginkgo recursively changes this to
@@ -60,6 +66,10 @@ var _ = BeforeEach(func() {
 
 var _ = AfterEach(func() {
 	Expect(os.Chdir(originalWorkingDir)).ToNot(HaveOccurred())
+
+	sqlDB, err := controllers.Settings.DB.DB()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(sqlDB.Close()).To(Succeed())
 })
 
 func generateSecret() (string, error) {
@@ -71,11 +81,18 @@ func generateSecret() (string, error) {
 }
 
 func performPostWithParams(router *gin.Engine, verb, path string, params map[string]string, cookie *http.Cookie) (*httptest.ResponseRecorder, *http.Cookie) {
-	w := httptest.NewRecorder()
 	form := url.Values{}
 	for k, v := range params {
 		form.Add(k, v)
 	}
+
+	return performPostWithForm(router, verb, path, form, cookie)
+}
+
+// performPostWithForm is like performPostWithParams but allows multiple values
+// per key (e.g. for multiple-choice answers)
+func performPostWithForm(router *gin.Engine, verb, path string, form url.Values, cookie *http.Cookie) (*httptest.ResponseRecorder, *http.Cookie) {
+	w := httptest.NewRecorder()
 	encodedForm := form.Encode()
 
 	req, err := http.NewRequest(verb, path, strings.NewReader(encodedForm))
@@ -88,9 +105,15 @@ func performPostWithParams(router *gin.Engine, verb, path string, params map[str
 	}
 
 	router.ServeHTTP(w, req)
+	// keep the session cookie separate from the rest (e.g. flashes)
 	var newCookie *http.Cookie
-	if cookies := w.Result().Cookies(); len(cookies) > 0 {
-		newCookie = w.Result().Cookies()[0]
+	otherCookies := []*http.Cookie{}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == controllers.COOKIE_NAME {
+			newCookie = c
+		} else {
+			otherCookies = append(otherCookies, c)
+		}
 	}
 	// no new cookie has been sent, keep the old one
 	if newCookie == nil {
@@ -108,6 +131,9 @@ func performPostWithParams(router *gin.Engine, verb, path string, params map[str
 	if newCookie != nil {
 		req.AddCookie(newCookie)
 	}
+	for _, c := range otherCookies {
+		req.AddCookie(c)
+	}
 	router.ServeHTTP(w, req)
 
 	return w, newCookie
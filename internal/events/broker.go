@@ -0,0 +1,86 @@
+package events
+
+import "sync"
+
+// Event is a server-sent event, e.g. a new version of the leaderboard
+type Event struct {
+	Name string
+	Data []byte
+}
+
+// Broker fans out events to any number of subscribers. Publishing never
+// blocks: a subscriber that is slow to read only gets the latest event,
+// which is fine for events that carry the whole current state.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+	closed      bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[chan Event]struct{}{}}
+}
+
+// Subscribe returns a channel with the published events and a function that
+// must be called to unsubscribe. The channel is closed on unsubscribe and
+// when the broker is closed.
+func (b *Broker) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, 1)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, found := b.subscribers[ch]; found {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Publish sends the event to all the subscribers, replacing any event they
+// haven't read yet.
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			// drop the stale event, only the publisher sends so there is room now
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- e
+		}
+	}
+}
+
+// Subscribers returns the number of current subscribers
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subscribers)
+}
+
+// Close unsubscribes everyone, e.g. to end the open streams on shutdown.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+	b.closed = true
+}
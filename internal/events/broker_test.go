@@ -0,0 +1,111 @@
+package events_test
+
+import (
+	"sync"
+
+	. "github.com/jimmykarily/quizmaker/internal/events"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Broker", func() {
+	var broker *Broker
+
+	BeforeEach(func() {
+		broker = NewBroker()
+	})
+
+	It("sends the events to every subscriber", func() {
+		first, unsubscribeFirst := broker.Subscribe()
+		defer unsubscribeFirst()
+		second, unsubscribeSecond := broker.Subscribe()
+		defer unsubscribeSecond()
+
+		broker.Publish(Event{Name: "leaderboard", Data: []byte("1")})
+
+		Expect((<-first).Data).To(Equal([]byte("1")))
+		Expect((<-second).Data).To(Equal([]byte("1")))
+	})
+
+	It("doesn't block on slow subscribers and keeps the latest event", func() {
+		events, unsubscribe := broker.Subscribe()
+		defer unsubscribe()
+
+		for _, data := range []string{"1", "2", "3"} {
+			broker.Publish(Event{Name: "leaderboard", Data: []byte(data)})
+		}
+
+		Expect((<-events).Data).To(Equal([]byte("3")))
+		Consistently(events).ShouldNot(Receive())
+	})
+
+	It("closes the channel on unsubscribe and on close", func() {
+		events, unsubscribe := broker.Subscribe()
+		unsubscribe()
+		unsubscribe() // twice is fine
+		Eventually(events).Should(BeClosed())
+
+		events, _ = broker.Subscribe()
+		Expect(broker.Subscribers()).To(Equal(1))
+		broker.Close()
+		Eventually(events).Should(BeClosed())
+		Expect(broker.Subscribers()).To(BeZero())
+
+		events, _ = broker.Subscribe()
+		Eventually(events).Should(BeClosed())
+	})
+
+	It("is safe for many concurrent subscribers", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 200; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				events, unsubscribe := broker.Subscribe()
+				defer unsubscribe()
+				<-events
+			}()
+		}
+
+		Eventually(broker.Subscribers).Should(Equal(200))
+		broker.Publish(Event{Name: "leaderboard"})
+		wg.Wait()
+		Expect(broker.Subscribers()).To(BeZero())
+	})
+})
+
+var _ = Describe("Topics", func() {
+	It("keeps the events of every topic separate", func() {
+		topics := NewTopics()
+		first, unsubscribeFirst := topics.Subscribe("first")
+		defer unsubscribeFirst()
+		second, unsubscribeSecond := topics.Subscribe("second")
+		defer unsubscribeSecond()
+
+		topics.Publish("first", Event{Name: "game", Data: []byte("1")})
+
+		Expect((<-first).Data).To(Equal([]byte("1")))
+		Consistently(second).ShouldNot(Receive())
+
+		topics.Close()
+		Eventually(first).Should(BeClosed())
+		Eventually(second).Should(BeClosed())
+	})
+
+	It("removes the topics without subscribers", func() {
+		topics := NewTopics()
+		first, unsubscribeFirst := topics.Subscribe("game")
+		_, unsubscribeSecond := topics.Subscribe("game")
+		Expect(topics.Len()).To(Equal(1))
+
+		unsubscribeSecond()
+		Expect(topics.Len()).To(Equal(1))
+		unsubscribeFirst()
+		unsubscribeFirst() // twice is fine
+		Expect(topics.Len()).To(BeZero())
+		Eventually(first).Should(BeClosed())
+
+		topics.Publish("game", Event{Name: "game"}) // nobody is listening
+		Expect(topics.Len()).To(BeZero())
+	})
+})
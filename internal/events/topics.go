@@ -0,0 +1,75 @@
+package events
+
+import "sync"
+
+// Topics is a set of brokers, one per topic (e.g. one per live game), so that
+// the subscribers of a topic only get its events. A topic only exists while
+// it has subscribers, so finished games don't pile up.
+type Topics struct {
+	mu      sync.Mutex
+	brokers map[string]*Broker
+	closed  bool
+}
+
+func NewTopics() *Topics {
+	return &Topics{brokers: map[string]*Broker{}}
+}
+
+// Subscribe subscribes to the topic, creating it if needed (see
+// Broker.Subscribe). The topic is removed when its last subscriber
+// unsubscribes.
+func (t *Topics) Subscribe(topic string) (<-chan Event, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, found := t.brokers[topic]
+	if !found {
+		b = NewBroker()
+		if t.closed {
+			b.Close()
+		}
+		t.brokers[topic] = b
+	}
+	ch, unsubscribe := b.Subscribe()
+
+	return ch, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		unsubscribe()
+		if b.Subscribers() == 0 && t.brokers[topic] == b {
+			delete(t.brokers, topic)
+		}
+	}
+}
+
+// Publish sends the event to the subscribers of the topic, if any (see
+// Broker.Publish)
+func (t *Topics) Publish(topic string, e Event) {
+	t.mu.Lock()
+	b, found := t.brokers[topic]
+	t.mu.Unlock()
+
+	if found {
+		b.Publish(e)
+	}
+}
+
+// Len returns the number of topics with subscribers
+func (t *Topics) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.brokers)
+}
+
+// Close closes all the brokers (see Broker.Close)
+func (t *Topics) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, b := range t.brokers {
+		b.Close()
+	}
+	t.closed = true
+}
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultAnalyticsMinSamples          = 5
+	DefaultAnalyticsDifficultyTolerance = 2
+
+	// OutlierTooHard marks questions answered right less often than their
+	// declared difficulty suggests
+	OutlierTooHard = "too hard"
+	// OutlierTooEasy marks questions answered right more often than their
+	// declared difficulty suggests
+	OutlierTooEasy = "too easy"
+)
+
+// AnalyticsOptions configure which questions QuestionAnalytics flags as
+// outliers
+type AnalyticsOptions struct {
+	// MinSamples is the number of scored answers needed before a question is
+	// flagged at all
+	MinSamples int
+	// DifficultyTolerance is how far the observed difficulty can be from the
+	// declared one before the question is flagged
+	DifficultyTolerance int
+}
+
+// QuestionStats aggregates the answers to a pool question (all the persisted
+// copies with the same PoolID)
+type QuestionStats struct {
+	PoolID             string
+	Text               string
+	Category           string
+	DeclaredDifficulty int
+	// ObservedDifficulty maps the rate of right answers onto the range of
+	// declared difficulties: questions nobody answers right get the maximum
+	ObservedDifficulty int
+	// Scored is the number of answered plus expired copies. Expired ones count
+	// as wrong, like in a Session.
+	Scored            int
+	Answered          int
+	Expired           int
+	PercentCorrect    float64
+	ExpiryRate        float64 // percentage of Scored
+	AverageAnswerTime time.Duration
+	Options           []OptionStats
+	Outlier           string // OutlierTooHard, OutlierTooEasy or empty
+}
+
+// OptionStats is how many times an answer was picked (or typed, for free
+// input questions)
+type OptionStats struct {
+	Text    string
+	Count   int
+	Percent float64 // of the answered copies
+	Right   bool
+}
+
+// QuestionAnalytics aggregates the persisted questions by PoolID and flags
+// the ones whose observed difficulty is far from the declared one. Voided
+// questions and questions nobody got to are left out. The hardest questions
+// come first.
+func QuestionAnalytics(db *gorm.DB, opts AnalyticsOptions) ([]QuestionStats, error) {
+	var persisted QuestionList
+	if err := db.Where("voided = ?", false).Order("id").Find(&persisted).Error; err != nil {
+		return nil, fmt.Errorf("looking up persisted questions: %w", err)
+	}
+
+	byPoolID := map[string]QuestionList{}
+	minDifficulty, maxDifficulty := 0, 0
+	for _, q := range persisted {
+		status := q.Status()
+		if status != QuestionAnswered && status != QuestionExpired {
+			continue
+		}
+		byPoolID[q.PoolID] = append(byPoolID[q.PoolID], q)
+		if minDifficulty == 0 || q.Difficulty < minDifficulty {
+			minDifficulty = q.Difficulty
+		}
+		if q.Difficulty > maxDifficulty {
+			maxDifficulty = q.Difficulty
+		}
+	}
+
+	result := []QuestionStats{}
+	for poolID, questions := range byPoolID {
+		stats := questionStats(poolID, questions, minDifficulty, maxDifficulty)
+		stats.Outlier = outlier(stats, opts)
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].PercentCorrect != result[j].PercentCorrect {
+			return result[i].PercentCorrect < result[j].PercentCorrect
+		}
+		return result[i].PoolID < result[j].PoolID
+	})
+
+	return result, nil
+}
+
+func questionStats(poolID string, questions QuestionList, minDifficulty, maxDifficulty int) QuestionStats {
+	latest := questions[len(questions)-1] // the text and answers may have been edited since
+	stats := QuestionStats{
+		PoolID:             poolID,
+		Text:               latest.Text,
+		Category:           latest.Category,
+		DeclaredDifficulty: latest.Difficulty,
+		Scored:             len(questions),
+	}
+
+	credit := 0.0
+	var answerTime time.Duration
+	timed := 0
+	options := map[string]*OptionStats{}
+	order := []string{}
+	if !latest.IsFreeInput() {
+		for i, text := range latest.Answers {
+			options[text] = &OptionStats{Text: text, Right: latest.CorrectAnswers().Contains(i + 1)}
+			order = append(order, text)
+		}
+	}
+
+	for _, q := range questions {
+		if q.Status() == QuestionExpired {
+			stats.Expired++
+			continue
+		}
+		stats.Answered++
+		credit += q.Credit()
+		if !q.AnsweredAt.IsZero() && !q.StartedAt.IsZero() {
+			answerTime += q.AnsweredAt.Sub(q.StartedAt)
+			timed++
+		}
+
+		for _, text := range q.UserAnswerTexts() {
+			if q.IsFreeInput() {
+				text = strings.ToLower(strings.TrimSpace(text))
+			}
+			option, found := options[text]
+			if !found {
+				option = &OptionStats{Text: text, Right: q.IsFreeInput() && q.Correct()}
+				options[text] = option
+				order = append(order, text)
+			}
+			option.Count++
+		}
+	}
+
+	stats.PercentCorrect = percent(credit, stats.Scored)
+	stats.ExpiryRate = percent(float64(stats.Expired), stats.Scored)
+	if timed > 0 {
+		stats.AverageAnswerTime = answerTime / time.Duration(timed)
+	}
+	stats.ObservedDifficulty = minDifficulty +
+		int(math.Round((1-credit/float64(stats.Scored))*float64(maxDifficulty-minDifficulty)))
+
+	for _, text := range order {
+		option := options[text]
+		option.Percent = percent(float64(option.Count), stats.Answered)
+		stats.Options = append(stats.Options, *option)
+	}
+	if latest.IsFreeInput() { // the most common answers first
+		sort.SliceStable(stats.Options, func(i, j int) bool {
+			return stats.Options[i].Count > stats.Options[j].Count
+		})
+	}
+
+	return stats
+}
+
+func outlier(stats QuestionStats, opts AnalyticsOptions) string {
+	if stats.Scored < opts.MinSamples {
+		return ""
+	}
+
+	switch diff := stats.ObservedDifficulty - stats.DeclaredDifficulty; {
+	case diff > opts.DifficultyTolerance:
+		return OutlierTooHard
+	case diff < -opts.DifficultyTolerance:
+		return OutlierTooEasy
+	}
+
+	return ""
+}
+
+func percent(part float64, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return math.Round(part/float64(total)*1000) / 10
+}
@@ -0,0 +1,88 @@
+package models_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("QuestionAnalytics", func() {
+	opts := AnalyticsOptions{MinSamples: 5, DifficultyTolerance: 2}
+	now := time.Now()
+
+	answered := func(poolID string, difficulty, answer int, seconds int) Question {
+		return Question{
+			PoolID: poolID, Text: "Question " + poolID, Difficulty: difficulty,
+			Answers: Answers{"right", "wrong"}, RightAnswer: 1, UserAnswer: answer,
+			StartedAt: now, AnsweredAt: now.Add(time.Duration(seconds) * time.Second), AllowedSeconds: 30,
+		}
+	}
+
+	BeforeEach(func() {
+		questions := []Question{}
+		for i := 0; i < 5; i++ {
+			questions = append(questions,
+				answered("easy", 1, 1, 2),
+				answered("broken", 1, 2, 10), // nobody gets it right
+				answered("tough", 5, 1, 4),   // everybody gets it right
+			)
+		}
+		expired := answered("easy", 1, 0, 0)
+		expired.AnsweredAt = time.Time{}
+		expired.ExpiredAt = now
+		voided := answered("voided", 3, 2, 1)
+		voided.Voided = true
+		pending := answered("pending", 3, 0, 0)
+		pending.StartedAt, pending.AnsweredAt = time.Time{}, time.Time{}
+		questions = append(questions, expired, voided, pending)
+
+		for i, q := range questions {
+			session, err := NewSession(db, fmt.Sprintf("user%d@example.com", i), "")
+			Expect(err).ToNot(HaveOccurred())
+			q.SessionID = session.ID
+			Expect(db.Create(&q).Error).ToNot(HaveOccurred())
+		}
+	})
+
+	It("aggregates the answers by question", func() {
+		stats, err := QuestionAnalytics(db, opts)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stats).To(HaveLen(3)) // no voided or pending questions
+
+		broken := stats[0] // the hardest first
+		Expect(broken.PoolID).To(Equal("broken"))
+		Expect(broken.PercentCorrect).To(BeZero())
+		Expect(broken.ObservedDifficulty).To(Equal(5))
+		Expect(broken.Outlier).To(Equal(OutlierTooHard))
+		Expect(broken.AverageAnswerTime).To(Equal(10 * time.Second))
+		Expect(broken.Options).To(Equal([]OptionStats{
+			{Text: "right", Count: 0, Percent: 0, Right: true},
+			{Text: "wrong", Count: 5, Percent: 100},
+		}))
+
+		easy := stats[1]
+		Expect(easy.PoolID).To(Equal("easy"))
+		Expect(easy.Scored).To(Equal(6))
+		Expect(easy.Expired).To(Equal(1))
+		Expect(easy.ExpiryRate).To(Equal(16.7))
+		Expect(easy.PercentCorrect).To(Equal(83.3))
+		Expect(easy.ObservedDifficulty).To(Equal(2))
+		Expect(easy.Outlier).To(BeEmpty())
+
+		tough := stats[2]
+		Expect(tough.PoolID).To(Equal("tough"))
+		Expect(tough.ObservedDifficulty).To(Equal(1))
+		Expect(tough.Outlier).To(Equal(OutlierTooEasy))
+	})
+
+	It("doesn't flag questions with too few answers", func() {
+		stats, err := QuestionAnalytics(db, AnalyticsOptions{MinSamples: 10, DifficultyTolerance: 2})
+		Expect(err).ToNot(HaveOccurred())
+		for _, s := range stats {
+			Expect(s.Outlier).To(BeEmpty())
+		}
+	})
+})
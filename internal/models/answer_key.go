@@ -0,0 +1,85 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// AnswerKeyChange describes a pool question whose answer key is different
+// from the one of already answered (persisted) copies of it.
+type AnswerKeyChange struct {
+	PoolID string
+	Text   string
+	// Questions are the persisted and answered questions with the old answer key
+	Questions QuestionList
+}
+
+// AnswerKey returns a representation of everything that decides whether an
+// answer is right. Two questions with the same AnswerKey grade answers the
+// same way. Whether the question counts at all (Voided) is not part of it,
+// because admins can change that on a running server (see SetVoided).
+func (q Question) AnswerKey() string {
+	key := struct {
+		Type            QuestionType
+		Correct         AnswerSet
+		Answers         Answers
+		Scoring         ScoringMode
+		AcceptedAnswers Answers
+		AnswerPattern   string
+		CaseSensitive   bool
+		Tolerance       float64
+	}{
+		Type:            q.Type,
+		Correct:         q.CorrectAnswers(),
+		Answers:         append(Answers{}, q.Answers...), // nil and empty are the same
+		Scoring:         q.Scoring,
+		AcceptedAnswers: append(Answers{}, q.AcceptedAnswers...),
+		AnswerPattern:   q.AnswerPattern,
+		CaseSensitive:   q.CaseSensitive,
+		Tolerance:       q.Tolerance,
+	}
+	b, _ := json.Marshal(key) // can't fail, all fields are plain values
+
+	return string(b)
+}
+
+// AnswerKeyChanges finds the questions of the pool that were answered by
+// someone before their answer key was changed in the pool.
+func AnswerKeyChanges(db *gorm.DB, pool QuestionPool) ([]AnswerKeyChange, error) {
+	poolQuestions := map[string]Question{}
+	ids := []string{}
+	for _, q := range pool.Questions {
+		poolQuestions[q.PoolID] = q
+		ids = append(ids, q.PoolID)
+	}
+
+	var persisted QuestionList
+	if err := db.Where("pool_id IN ?", ids).Order("id").Find(&persisted).Error; err != nil {
+		return nil, fmt.Errorf("looking up persisted questions: %w", err)
+	}
+
+	changes := map[string]*AnswerKeyChange{}
+	result := []AnswerKeyChange{}
+	order := []string{}
+	for _, q := range persisted {
+		if !q.Answered() {
+			continue
+		}
+		current := poolQuestions[q.PoolID]
+		if current.AnswerKey() == q.AnswerKey() {
+			continue
+		}
+		if changes[q.PoolID] == nil {
+			changes[q.PoolID] = &AnswerKeyChange{PoolID: q.PoolID, Text: current.Text}
+			order = append(order, q.PoolID)
+		}
+		changes[q.PoolID].Questions = append(changes[q.PoolID].Questions, q)
+	}
+	for _, id := range order {
+		result = append(result, *changes[id])
+	}
+
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package models_test
+
+import (
+	. "github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AnswerKeyChanges", func() {
+	var pool QuestionPool
+
+	BeforeEach(func() {
+		var err error
+		pool, err = NewQuestionPool(`
+questions:
+  - id: etcd-port
+    text: Which port does etcd listen on?
+    rightAnswer: 1
+    answers: ["2379", "6443"]
+  - text: Which one is a container runtime?
+    rightAnswer: 2
+    answers: [systemd, containerd]
+`)
+		Expect(err).ToNot(HaveOccurred())
+
+		session := Session{Email: "john.doe@example.com"}
+		Expect(db.Create(&session).Error).ToNot(HaveOccurred())
+		questions := QuestionList{}
+		for _, q := range pool.Questions {
+			q.SessionID = session.ID
+			q.UserAnswer = 1
+			questions = append(questions, q)
+		}
+		// an unanswered copy doesn't count
+		unanswered := pool.Questions[0]
+		unanswered.SessionID = session.ID
+		questions = append(questions, unanswered)
+		Expect(db.Create(&questions).Error).ToNot(HaveOccurred())
+	})
+
+	It("returns nothing when the answer keys didn't change", func() {
+		changes, err := AnswerKeyChanges(db, pool)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changes).To(BeEmpty())
+	})
+
+	It("returns the answered questions whose answer key changed", func() {
+		pool.Questions[0].RightAnswer = 2
+		pool.Questions[1].Text = "Fixed typo" // text changes don't affect the answer key
+
+		changes, err := AnswerKeyChanges(db, pool)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(changes)).To(Equal(1))
+		Expect(changes[0].PoolID).To(Equal("etcd-port"))
+		Expect(len(changes[0].Questions)).To(Equal(1))
+		Expect(changes[0].Questions[0].RightAnswer).To(Equal(1))
+	})
+	It("ignores questions that were only voided", func() {
+		_, err := SetVoided(db, "etcd-port", true)
+		Expect(err).ToNot(HaveOccurred())
+
+		changes, err := AnswerKeyChanges(db, pool)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changes).To(BeEmpty())
+	})
+})
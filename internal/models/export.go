@@ -0,0 +1,259 @@
+package models
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ExportStateAll        = "all"
+	ExportStateComplete   = "complete"
+	ExportStateInProgress = "in-progress"
+
+	exportDateFormat = "2006-01-02"
+)
+
+// ExportFilter selects the sessions to export
+type ExportFilter struct {
+	From  time.Time // sessions started at or after it, zero for no limit
+	To    time.Time // sessions started before it, zero for no limit
+	State string    // one of the ExportState* values, empty means all
+}
+
+// ExportedSession is a session as it's exported, with its answers
+type ExportedSession struct {
+	Email           string           `json:"email"`
+	Nickname        string           `json:"nickname"`
+	Score           int              `json:"score"`
+	Points          int              `json:"points"`
+	Rank            int              `json:"rank,omitempty"` // only complete sessions on the leaderboard have a rank
+	Complete        bool             `json:"complete"`
+	Disqualified    bool             `json:"disqualified"`
+	StartedAt       time.Time        `json:"startedAt"`
+	CompletedAt     *time.Time       `json:"completedAt,omitempty"`
+	TotalAnswerTime float64          `json:"totalAnswerSeconds"`
+	Answers         []ExportedAnswer `json:"answers"`
+}
+
+// ExportedAnswer is a question of an exported session along with the answer
+// of the participant
+type ExportedAnswer struct {
+	Index         int            `json:"index"`
+	QuestionID    string         `json:"questionId"` // the PoolID
+	Question      string         `json:"question"`
+	Category      string         `json:"category,omitempty"`
+	Difficulty    int            `json:"difficulty"`
+	Answer        []string       `json:"answer"`
+	RightAnswer   []string       `json:"rightAnswer"`
+	Correct       bool           `json:"correct"`
+	Status        QuestionStatus `json:"status"`
+	AnswerSeconds float64        `json:"answerSeconds,omitempty"` // only for answered questions
+}
+
+// ParseExportFilter parses the values of the command line flags (or query
+// parameters) of an export. Dates are either "2006-01-02" or RFC3339. A date
+// without a time in `to` includes the whole day.
+func ParseExportFilter(from, to, state string) (ExportFilter, error) {
+	filter := ExportFilter{State: state}
+
+	switch state {
+	case "", ExportStateAll, ExportStateComplete, ExportStateInProgress:
+	default:
+		return filter, fmt.Errorf("invalid state %q, expected one of %s, %s, %s",
+			state, ExportStateAll, ExportStateComplete, ExportStateInProgress)
+	}
+
+	var err error
+	if filter.From, _, err = parseExportDate(from); err != nil {
+		return filter, fmt.Errorf("invalid from date: %w", err)
+	}
+	var dateOnly bool
+	if filter.To, dateOnly, err = parseExportDate(to); err != nil {
+		return filter, fmt.Errorf("invalid to date: %w", err)
+	}
+	if dateOnly {
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+
+	return filter, nil
+}
+
+func parseExportDate(value string) (time.Time, bool, error) {
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.ParseInLocation(exportDateFormat, value, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+
+	return t, false, err
+}
+
+// ExportSessions returns the sessions that match the filter with their
+// answers, oldest first. Ranks are those of the whole leaderboard.
+func ExportSessions(db *gorm.DB, filter ExportFilter) ([]ExportedSession, error) {
+	query := db.Preload("Questions").Order("created_at")
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	switch filter.State {
+	case ExportStateComplete:
+		query = query.Where("complete = ?", true)
+	case ExportStateInProgress:
+		query = query.Where("complete = ?", false)
+	}
+
+	sessions := []Session{}
+	if err := query.Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("looking up sessions: %w", err)
+	}
+
+	leaderboard, err := CompletedLeaderboard(db)
+	if err != nil {
+		return nil, err
+	}
+	ranks := map[uint]int{}
+	for _, s := range leaderboard {
+		ranks[s.ID] = s.Rank
+	}
+
+	result := []ExportedSession{}
+	for _, s := range sessions {
+		result = append(result, exportSession(s, ranks[s.ID]))
+	}
+
+	return result, nil
+}
+
+func exportSession(s Session, rank int) ExportedSession {
+	exported := ExportedSession{
+		Email:           s.Email,
+		Nickname:        s.Nickname,
+		Score:           s.Score,
+		Points:          s.Points,
+		Rank:            rank,
+		Complete:        s.Complete,
+		Disqualified:    s.Disqualified,
+		StartedAt:       s.CreatedAt,
+		TotalAnswerTime: s.TotalAnswerTime.Seconds(),
+		Answers:         []ExportedAnswer{},
+	}
+	if s.Complete && !s.CompletedAt.IsZero() {
+		completedAt := s.CompletedAt
+		exported.CompletedAt = &completedAt
+	}
+
+	sort.Slice(s.Questions, func(i, j int) bool {
+		return s.Questions[i].Index < s.Questions[j].Index
+	})
+	for _, q := range s.Questions {
+		a := ExportedAnswer{
+			Index:       q.Index,
+			QuestionID:  q.PoolID,
+			Question:    q.Text,
+			Category:    q.Category,
+			Difficulty:  q.Difficulty,
+			Answer:      q.UserAnswerTexts(),
+			RightAnswer: q.RightAnswerTexts(),
+			Correct:     q.Answered() && q.Correct(),
+			Status:      q.Status(),
+		}
+		if q.Answered() && !q.AnsweredAt.IsZero() && !q.StartedAt.IsZero() {
+			a.AnswerSeconds = q.AnsweredAt.Sub(q.StartedAt).Seconds()
+		}
+		exported.Answers = append(exported.Answers, a)
+	}
+
+	return exported
+}
+
+// WriteExportJSON writes the sessions as a JSON array
+func WriteExportJSON(w io.Writer, sessions []ExportedSession) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(sessions)
+}
+
+// WriteExportCSV writes the sessions as CSV with one row per answer. The
+// columns of the session are repeated on every row. Sessions without
+// questions get a single row with empty answer columns. Text that comes from
+// participants or the pool is escaped (see csvText).
+func WriteExportCSV(w io.Writer, sessions []ExportedSession) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{
+		"email", "nickname", "score", "points", "rank", "complete", "disqualified",
+		"started_at", "completed_at", "total_answer_seconds",
+		"question_index", "question_id", "question", "category", "difficulty",
+		"answer", "right_answer", "correct", "status", "answer_seconds",
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sessions {
+		completedAt := ""
+		if s.CompletedAt != nil {
+			completedAt = s.CompletedAt.Format(time.RFC3339)
+		}
+		rank := ""
+		if s.Rank > 0 {
+			rank = strconv.Itoa(s.Rank)
+		}
+		sessionColumns := []string{
+			csvText(s.Email), csvText(s.Nickname), strconv.Itoa(s.Score), strconv.Itoa(s.Points), rank,
+			strconv.FormatBool(s.Complete), strconv.FormatBool(s.Disqualified),
+			s.StartedAt.Format(time.RFC3339), completedAt, formatSeconds(s.TotalAnswerTime),
+		}
+
+		if len(s.Answers) == 0 {
+			if err := writer.Write(append(sessionColumns, make([]string, 10)...)); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, a := range s.Answers {
+			answerSeconds := ""
+			if a.AnswerSeconds > 0 {
+				answerSeconds = formatSeconds(a.AnswerSeconds)
+			}
+			row := append(append([]string{}, sessionColumns...),
+				strconv.Itoa(a.Index), csvText(a.QuestionID), csvText(a.Question), csvText(a.Category), strconv.Itoa(a.Difficulty),
+				csvText(strings.Join(a.Answer, "; ")), csvText(strings.Join(a.RightAnswer, "; ")),
+				strconv.FormatBool(a.Correct), string(a.Status), answerSeconds,
+			)
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
+
+// csvText prefixes text that spreadsheets would take for a formula (e.g. a
+// nickname like "=HYPERLINK(...)") with a "'", so that it's shown as text.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
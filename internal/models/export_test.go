@@ -0,0 +1,119 @@
+package models_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"time"
+
+	. "github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Export", func() {
+	var yesterday, today time.Time
+
+	BeforeEach(func() {
+		today = time.Now()
+		yesterday = today.Add(-24 * time.Hour)
+
+		alice := Session{Email: "alice@example.com", Nickname: "alice", Score: 50, Complete: true, CompletedAt: today}
+		alice.CreatedAt = today
+		Expect(db.Create(&alice).Error).ToNot(HaveOccurred())
+		quiz := Quiz{Questions: []Question{
+			{PoolID: "q1", Text: "Q1", Answers: Answers{"a", "b"}, RightAnswer: 1, UserAnswer: 1,
+				StartedAt: today, AnsweredAt: today.Add(3 * time.Second), AllowedSeconds: 30},
+			{PoolID: "q2", Text: "Q2", Answers: Answers{"a", "b"}, RightAnswer: 1, UserAnswer: 2,
+				StartedAt: today, AnsweredAt: today.Add(5 * time.Second), AllowedSeconds: 30},
+		}}
+		Expect(quiz.PersistForSessionEmail(db, alice.Email)).To(Succeed())
+
+		bob := Session{Email: "bob@example.com", Nickname: "bob"}
+		bob.CreatedAt = yesterday
+		Expect(db.Create(&bob).Error).ToNot(HaveOccurred())
+	})
+
+	It("exports the sessions with their answers", func() {
+		sessions, err := ExportSessions(db, ExportFilter{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sessions).To(HaveLen(2))
+		Expect(sessions[0].Email).To(Equal("bob@example.com"))
+		Expect(sessions[0].Answers).To(BeEmpty())
+
+		alice := sessions[1]
+		Expect(alice.Rank).To(Equal(1))
+		Expect(alice.Answers).To(HaveLen(2))
+		Expect(alice.Answers[0].QuestionID).To(Equal("q1"))
+		Expect(alice.Answers[0].Correct).To(BeTrue())
+		Expect(alice.Answers[0].AnswerSeconds).To(BeNumerically("~", 3, 0.01))
+		Expect(alice.Answers[1].Answer).To(Equal([]string{"b"}))
+		Expect(alice.Answers[1].Correct).To(BeFalse())
+	})
+
+	It("filters by date and state", func() {
+		filter, err := ParseExportFilter(yesterday.Format("2006-01-02"), yesterday.Format("2006-01-02"), "")
+		Expect(err).ToNot(HaveOccurred())
+		sessions, err := ExportSessions(db, filter)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sessions).To(HaveLen(1))
+		Expect(sessions[0].Email).To(Equal("bob@example.com"))
+
+		filter, err = ParseExportFilter("", "", ExportStateComplete)
+		Expect(err).ToNot(HaveOccurred())
+		sessions, err = ExportSessions(db, filter)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sessions).To(HaveLen(1))
+		Expect(sessions[0].Email).To(Equal("alice@example.com"))
+
+		_, err = ParseExportFilter("", "", "done")
+		Expect(err).To(MatchError(ContainSubstring("invalid state")))
+		_, err = ParseExportFilter("yesterday", "", "")
+		Expect(err).To(MatchError(ContainSubstring("invalid from date")))
+	})
+
+	It("writes one CSV row per answer", func() {
+		sessions, err := ExportSessions(db, ExportFilter{})
+		Expect(err).ToNot(HaveOccurred())
+
+		var buf bytes.Buffer
+		Expect(WriteExportCSV(&buf, sessions)).To(Succeed())
+		rows, err := csv.NewReader(&buf).ReadAll()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rows).To(HaveLen(4)) // header, bob without answers, 2 answers of alice
+		Expect(rows[0][0]).To(Equal("email"))
+		Expect(rows[1][0]).To(Equal("bob@example.com"))
+		Expect(rows[3][0]).To(Equal("alice@example.com"))
+		Expect(rows[3][11]).To(Equal("q2"))
+		Expect(rows[3][15]).To(Equal("b"))
+		Expect(rows[3][17]).To(Equal("false"))
+	})
+
+	It("escapes the CSV cells that spreadsheets would take for formulas", func() {
+		Expect(db.Model(&Session{}).Where("email = ?", "bob@example.com").
+			Update("nickname", `=HYPERLINK("http://example.com")`).Error).To(Succeed())
+		sessions, err := ExportSessions(db, ExportFilter{})
+		Expect(err).ToNot(HaveOccurred())
+
+		var buf bytes.Buffer
+		Expect(WriteExportCSV(&buf, sessions)).To(Succeed())
+		rows, err := csv.NewReader(&buf).ReadAll()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rows[1][1]).To(Equal(`'=HYPERLINK("http://example.com")`))
+		Expect(rows[2][1]).To(Equal("alice"))
+		Expect(rows[2][2]).To(Equal("50")) // only text is escaped
+	})
+
+	It("writes JSON", func() {
+		sessions, err := ExportSessions(db, ExportFilter{})
+		Expect(err).ToNot(HaveOccurred())
+
+		var buf bytes.Buffer
+		Expect(WriteExportJSON(&buf, sessions)).To(Succeed())
+		var decoded []map[string]interface{}
+		Expect(json.Unmarshal(buf.Bytes(), &decoded)).To(Succeed())
+		Expect(decoded).To(HaveLen(2))
+		Expect(decoded[1]).To(HaveKeyWithValue("email", "alice@example.com"))
+		Expect(decoded[1]["answers"]).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,415 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GameState is the state of a live Game, see Game.Next and Game.Reveal
+type GameState string
+
+const (
+	// GameLobby is the state before the first question, while players join
+	GameLobby GameState = "lobby"
+	// GameAsking is the state while the current question can be answered
+	GameAsking GameState = "asking"
+	// GameRevealing is the state while the right answer of the current
+	// question is shown
+	GameRevealing GameState = "revealing"
+	GameFinished  GameState = "finished"
+
+	GameCodeLength = 6
+	// DefaultGameSpeedBonus is used when the scoring configuration doesn't set
+	// a speed bonus. Answering fast is the point of a live game.
+	DefaultGameSpeedBonus = 1.0
+
+	gameCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // no 0/O, 1/I
+)
+
+var (
+	ErrGameNotAsking = errors.New("no question is being asked right now")
+	ErrGameFinished  = errors.New("the game has finished")
+	ErrInvalidAnswer = errors.New("invalid answer")
+	ErrGameChanged   = errors.New("the game has moved on in the meantime")
+	// ErrNotEnoughQuestions is returned by NewGame when the pool doesn't have
+	// enough questions for the requested game
+	ErrNotEnoughQuestions = errors.New("not enough questions for a game")
+	ErrNicknameRequired   = errors.New("nickname is required")
+	ErrNicknameTooLong    = fmt.Errorf("nickname is longer than %d characters", MaxNicknameLength)
+)
+
+// MaxNicknameLength is the maximum number of characters of a nickname (the
+// forms limit it too, but only in the browser)
+const MaxNicknameLength = 30
+
+// Game is a live quiz where a host advances the questions for all the players
+// at once, as opposed to the self-paced Session. Every player answers the
+// same questions and earns points for right and fast answers.
+type Game struct {
+	gorm.Model
+	Code      string `gorm:"uniqueIndex"` // what players type (or scan) to join
+	HostToken string // proves that a browser is the host's
+	State     GameState
+	Current   int           // the Index of the current question, 0 in the lobby
+	Scoring   ScoringConfig `gorm:"embedded;embeddedPrefix:scoring_"`
+	Questions []GameQuestion
+	Players   []GamePlayer
+}
+
+// GameQuestion is a question of a Game. The question is copied from the pool.
+type GameQuestion struct {
+	gorm.Model
+	GameID        uint `gorm:"index"`
+	Index         int
+	Question      Question  `gorm:"serializer:json"`
+	StartedAt     time.Time `gorm:"serializer:nulltime"`
+	RevealedAt    time.Time `gorm:"serializer:nulltime"`
+	PlayerAnswers []GameAnswer
+}
+
+type GamePlayer struct {
+	gorm.Model
+	GameID   uint `gorm:"index"`
+	Nickname string
+	Token    string // identifies the player's browser
+	Points   int
+	Rank     int `gorm:"-"` // set by Game.Leaderboard
+}
+
+// GameAnswer is the answer of a player to a GameQuestion. Points are set when
+// the answer is revealed.
+type GameAnswer struct {
+	gorm.Model
+	GameQuestionID uint `gorm:"uniqueIndex:idx_game_answers_question_player"`
+	GamePlayerID   uint `gorm:"uniqueIndex:idx_game_answers_question_player"`
+	Answer         int
+	AnsweredAt     time.Time `gorm:"serializer:nulltime"`
+	Points         int
+}
+
+// NewGame picks the questions of a new game like NewQuizWithOpts does for a
+// Session, but only out of the questions that are answered with a single
+// tap (see QuestionList.SingleAnswer).
+func NewGame(db *gorm.DB, opts QuizOptions) (Game, error) {
+	opts.AvailableQuestions = opts.AvailableQuestions.SingleAnswer()
+	quiz, err := NewQuizWithOpts(opts)
+	if err != nil {
+		return Game{}, fmt.Errorf("%w: %w", ErrNotEnoughQuestions, err)
+	}
+
+	game := Game{State: GameLobby, Scoring: quiz.Scoring}
+	if game.Scoring.SpeedBonus == 0 {
+		game.Scoring.SpeedBonus = DefaultGameSpeedBonus
+	}
+	if game.Code, err = randomGameCode(); err != nil {
+		return game, err
+	}
+	if game.HostToken, err = randomToken(); err != nil {
+		return game, err
+	}
+	for i, q := range quiz.Questions {
+		game.Questions = append(game.Questions, GameQuestion{Index: i + 1, Question: q})
+	}
+
+	if err := db.Create(&game).Error; err != nil {
+		return game, fmt.Errorf("creating game: %w", err)
+	}
+
+	return game, nil
+}
+
+// GameForCode loads the game with the given code along with its questions,
+// answers and players.
+func GameForCode(db *gorm.DB, code string) (Game, error) {
+	var game Game
+	err := db.
+		Preload("Questions", func(db *gorm.DB) *gorm.DB {
+			return db.Order(clause.OrderByColumn{Column: clause.Column{Name: "index"}})
+		}).
+		Preload("Questions.PlayerAnswers").
+		Preload("Players").
+		First(&game, "code = ?", strings.ToUpper(strings.TrimSpace(code))).Error
+
+	return game, err
+}
+
+// CurrentQuestion returns the question being asked or revealed
+func (g Game) CurrentQuestion() (GameQuestion, bool) {
+	for _, q := range g.Questions {
+		if q.Index == g.Current {
+			return q, true
+		}
+	}
+
+	return GameQuestion{}, false
+}
+
+// Player returns the player of this game with the given token
+func (g Game) Player(token string) (GamePlayer, bool) {
+	for _, p := range g.Players {
+		if token != "" && p.Token == token {
+			return p, true
+		}
+	}
+
+	return GamePlayer{}, false
+}
+
+// Join adds a new player to the game
+func (g *Game) Join(db *gorm.DB, nickname string) (GamePlayer, error) {
+	player := GamePlayer{GameID: g.ID, Nickname: strings.TrimSpace(nickname)}
+	if g.State == GameFinished {
+		return player, ErrGameFinished
+	}
+	if player.Nickname == "" {
+		return player, ErrNicknameRequired
+	}
+	if utf8.RuneCountInString(player.Nickname) > MaxNicknameLength {
+		return player, ErrNicknameTooLong
+	}
+
+	var err error
+	if player.Token, err = randomToken(); err != nil {
+		return player, err
+	}
+	if err := db.Create(&player).Error; err != nil {
+		return player, fmt.Errorf("joining game: %w", err)
+	}
+	g.Players = append(g.Players, player)
+
+	return player, nil
+}
+
+// Next reveals the current question if needed and asks the next one. After
+// the last question the game is finished.
+func (g *Game) Next(db *gorm.DB, now time.Time) error {
+	switch g.State {
+	case GameFinished:
+		return ErrGameFinished
+	case GameAsking:
+		if err := g.Reveal(db, now); err != nil {
+			return err
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if g.Current >= len(g.Questions) {
+			if err := g.transition(tx, GameFinished, g.Current); err != nil {
+				return err
+			}
+			g.State = GameFinished
+			return nil
+		}
+
+		if err := g.transition(tx, GameAsking, g.Current+1); err != nil {
+			return err
+		}
+		g.Current++
+		g.State = GameAsking
+		for i := range g.Questions {
+			if g.Questions[i].Index != g.Current {
+				continue
+			}
+			g.Questions[i].StartedAt = now
+			if err := tx.Model(&g.Questions[i]).Update("started_at", now).Error; err != nil {
+				return fmt.Errorf("starting question %d: %w", g.Current, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Reveal stops accepting answers to the current question and gives points
+// to the players that answered it right.
+func (g *Game) Reveal(db *gorm.DB, now time.Time) error {
+	if g.State != GameAsking {
+		return ErrGameNotAsking
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := g.transition(tx, GameRevealing, g.Current); err != nil {
+			return err
+		}
+		g.State = GameRevealing
+
+		for i := range g.Questions {
+			gq := &g.Questions[i]
+			if gq.Index != g.Current {
+				continue
+			}
+
+			// answers may have arrived since the game was loaded
+			if err := tx.Where("game_question_id = ?", gq.ID).Find(&gq.PlayerAnswers).Error; err != nil {
+				return fmt.Errorf("looking up the answers to question %d: %w", g.Current, err)
+			}
+			for j := range gq.PlayerAnswers {
+				a := &gq.PlayerAnswers[j]
+				a.Points = g.Scoring.Points(gq.answered(*a))
+				if err := tx.Model(a).Update("points", a.Points).Error; err != nil {
+					return fmt.Errorf("scoring answer %d: %w", a.ID, err)
+				}
+				if err := tx.Model(&GamePlayer{}).Where("id = ?", a.GamePlayerID).
+					Update("points", gorm.Expr("points + ?", a.Points)).Error; err != nil {
+					return fmt.Errorf("updating the points of player %d: %w", a.GamePlayerID, err)
+				}
+				for k := range g.Players {
+					if g.Players[k].ID == a.GamePlayerID {
+						g.Players[k].Points += a.Points
+					}
+				}
+			}
+
+			gq.RevealedAt = now
+			if err := tx.Model(gq).Update("revealed_at", now).Error; err != nil {
+				return fmt.Errorf("revealing question %d: %w", g.Current, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// transition moves the game to the given state and question, unless someone
+// else (e.g. the host in another tab) already moved it since it was loaded.
+func (g Game) transition(tx *gorm.DB, state GameState, current int) error {
+	result := tx.Model(&Game{}).
+		Where("id = ? AND state = ? AND current = ?", g.ID, g.State, g.Current).
+		Updates(map[string]interface{}{"state": state, "current": current})
+	if result.Error != nil {
+		return fmt.Errorf("updating game %s: %w", g.Code, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrGameChanged
+	}
+
+	return nil
+}
+
+// Answer records the answer (a 1-based index) of the player to the current
+// question. Like in a Session, the grace period allows for network latency.
+func (g *Game) Answer(db *gorm.DB, player GamePlayer, answer int, now time.Time, grace time.Duration) error {
+	if g.State != GameAsking {
+		return ErrGameNotAsking
+	}
+	gq, found := g.CurrentQuestion()
+	if !found {
+		return ErrGameNotAsking
+	}
+	if answer < 1 || answer > len(gq.Question.Answers) {
+		return ErrInvalidAnswer
+	}
+	if gq.AnswerOf(player.ID) != nil {
+		return ErrQuestionAnswered
+	}
+
+	if now.After(gq.Deadline().Add(grace)) {
+		return ErrQuestionExpired
+	}
+
+	a := GameAnswer{GameQuestionID: gq.ID, GamePlayerID: player.ID, Answer: answer, AnsweredAt: now}
+	if err := db.Create(&a).Error; err != nil {
+		// most likely a second answer that arrived at the same time
+		return fmt.Errorf("%w: %w", ErrQuestionAnswered, err)
+	}
+	for i := range g.Questions {
+		if g.Questions[i].ID == gq.ID {
+			g.Questions[i].PlayerAnswers = append(g.Questions[i].PlayerAnswers, a)
+		}
+	}
+
+	return nil
+}
+
+// Leaderboard returns the players by points (and by time of joining when
+// equal). Players with the same points share the same rank.
+func (g Game) Leaderboard() []GamePlayer {
+	result := append([]GamePlayer{}, g.Players...)
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Points != result[j].Points {
+			return result[i].Points > result[j].Points
+		}
+		return result[i].ID < result[j].ID
+	})
+	for i := range result {
+		if i > 0 && result[i].Points == result[i-1].Points {
+			result[i].Rank = result[i-1].Rank
+		} else {
+			result[i].Rank = i + 1
+		}
+	}
+
+	return result
+}
+
+// AnswerOf returns the answer of the given player or nil
+func (gq GameQuestion) AnswerOf(playerID uint) *GameAnswer {
+	for i := range gq.PlayerAnswers {
+		if gq.PlayerAnswers[i].GamePlayerID == playerID {
+			return &gq.PlayerAnswers[i]
+		}
+	}
+
+	return nil
+}
+
+// Distribution returns how many players picked each answer
+func (gq GameQuestion) Distribution() []int {
+	result := make([]int, len(gq.Question.Answers))
+	for _, a := range gq.PlayerAnswers {
+		if a.Answer >= 1 && a.Answer <= len(result) {
+			result[a.Answer-1]++
+		}
+	}
+
+	return result
+}
+
+// Deadline returns when the time to answer runs out
+func (gq GameQuestion) Deadline() time.Time {
+	return gq.StartedAt.Add(time.Duration(gq.Question.AllowedSeconds) * time.Second)
+}
+
+// answered returns the question as answered with the given answer, so that it
+// can be scored like the questions of a Session.
+func (gq GameQuestion) answered(a GameAnswer) Question {
+	q := gq.Question
+	q.UserAnswer = a.Answer
+	q.StartedAt = gq.StartedAt
+	q.AnsweredAt = a.AnsweredAt
+
+	return q
+}
+
+func randomGameCode() (string, error) {
+	var sb strings.Builder
+	max := big.NewInt(int64(len(gameCodeAlphabet)))
+	for i := 0; i < GameCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("generating game code: %w", err)
+		}
+		sb.WriteByte(gameCodeAlphabet[n.Int64()])
+	}
+
+	return sb.String(), nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,147 @@
+package models_test
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Game", func() {
+	var game Game
+	var alice, bob GamePlayer
+	var now time.Time
+
+	BeforeEach(func() {
+		pool, err := NewQuestionPool(`
+questions:
+  - text: Question 1
+    difficulty: 1
+    rightAnswer: 1
+    answers: [a, b]
+  - text: Question 2
+    difficulty: 2
+    type: boolean
+    rightAnswer: 2
+    answers: ["true", "false"]
+  - text: Multiple choice
+    difficulty: 1
+    type: multiple-choice
+    rightAnswers: [1, 2]
+    answers: [a, b, c]
+  - text: Text question
+    difficulty: 1
+    type: text
+    acceptedAnswers: [etcd]
+`)
+		Expect(err).ToNot(HaveOccurred())
+
+		opts := QuizOptionsFor(pool, QuizConfig{TotalQuestions: 2, QuestionTimeoutSec: 20})
+		game, err = NewGame(db, opts)
+		Expect(err).ToNot(HaveOccurred())
+
+		alice, err = game.Join(db, "alice")
+		Expect(err).ToNot(HaveOccurred())
+		bob, err = game.Join(db, " bob ")
+		Expect(err).ToNot(HaveOccurred())
+
+		now = time.Now()
+	})
+
+	reload := func() Game {
+		g, err := GameForCode(db, game.Code)
+		Expect(err).ToNot(HaveOccurred())
+		return g
+	}
+
+	It("picks only questions that are answered with a single tap", func() {
+		game = reload()
+		Expect(game.State).To(Equal(GameLobby))
+		Expect(game.Code).To(HaveLen(GameCodeLength))
+		Expect(game.HostToken).ToNot(BeEmpty())
+		Expect(game.Questions).To(HaveLen(2))
+		Expect(game.Questions[0].Question.Text).To(Equal("Question 1"))
+		Expect(game.Questions[1].Question.Text).To(Equal("Question 2"))
+		Expect(game.Questions[1].Question.Answers).To(Equal(Answers{"true", "false"}))
+		Expect(game.Questions[1].Question.AllowedSeconds).To(Equal(20))
+	})
+
+	It("finds the game by code case insensitively", func() {
+		g, err := GameForCode(db, " "+strings.ToLower(game.Code)+" ")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(g.ID).To(Equal(game.ID))
+		Expect(g.Players).To(HaveLen(2))
+		Expect(g.Players[1].Nickname).To(Equal("bob"))
+	})
+
+	It("doesn't accept answers in the lobby", func() {
+		Expect(game.Answer(db, alice, 1, now, 0)).To(MatchError(ErrGameNotAsking))
+	})
+
+	It("asks the questions, scores the answers and finishes", func() {
+		Expect(game.Next(db, now)).To(Succeed())
+		Expect(game.State).To(Equal(GameAsking))
+		Expect(game.Current).To(Equal(1))
+
+		game = reload()
+		Expect(game.Answer(db, alice, 1, now, 0)).To(Succeed())
+		Expect(game.Answer(db, alice, 2, now, 0)).To(MatchError(ErrQuestionAnswered))
+		Expect(game.Answer(db, bob, 3, now, 0)).To(MatchError(ErrInvalidAnswer))
+		Expect(game.Answer(db, bob, 2, now.Add(10*time.Second), 0)).To(Succeed())
+
+		current, found := game.CurrentQuestion()
+		Expect(found).To(BeTrue())
+		Expect(current.Distribution()).To(Equal([]int{1, 1}))
+
+		Expect(game.Reveal(db, now.Add(15*time.Second))).To(Succeed())
+		Expect(game.State).To(Equal(GameRevealing))
+
+		game = reload()
+		leaderboard := game.Leaderboard()
+		Expect(leaderboard[0].Nickname).To(Equal("alice"))
+		Expect(leaderboard[0].Points).To(Equal(200)) // instant answer, full speed bonus
+		Expect(leaderboard[1].Points).To(BeZero())
+
+		Expect(game.Next(db, now.Add(20*time.Second))).To(Succeed())
+		Expect(game.State).To(Equal(GameAsking))
+		Expect(game.Current).To(Equal(2))
+
+		game = reload()
+		Expect(game.Answer(db, bob, 2, now.Add(21*time.Second), 0)).To(Succeed())
+		Expect(game.Answer(db, alice, 1, now.Add(30*time.Second), 0)).To(Succeed())
+
+		// moving on after the last question reveals it and finishes the game
+		Expect(game.Next(db, now.Add(45*time.Second))).To(Succeed())
+		Expect(game.State).To(Equal(GameFinished))
+		Expect(game.Next(db, now)).To(MatchError(ErrGameFinished))
+
+		game = reload()
+		Expect(game.State).To(Equal(GameFinished))
+		leaderboard = game.Leaderboard()
+		Expect(leaderboard[0].Nickname).To(Equal("bob"))
+		Expect(leaderboard[0].Points).To(Equal(390)) // difficulty 2, answered after 1 of 20 seconds
+		Expect(leaderboard[0].Rank).To(Equal(1))
+		Expect(leaderboard[1].Nickname).To(Equal("alice"))
+		Expect(leaderboard[1].Rank).To(Equal(2))
+	})
+
+	It("rejects late answers", func() {
+		Expect(game.Next(db, now)).To(Succeed())
+		Expect(game.Answer(db, alice, 1, now.Add(25*time.Second), 0)).To(MatchError(ErrQuestionExpired))
+		Expect(game.Answer(db, alice, 1, now.Add(25*time.Second), 10*time.Second)).To(Succeed())
+	})
+
+	It("doesn't move on twice when two hosts click at the same time", func() {
+		stale := reload()
+		Expect(game.Next(db, now)).To(Succeed())
+		Expect(stale.Next(db, now)).To(MatchError(ErrGameChanged))
+		Expect(reload().Current).To(Equal(1))
+	})
+
+	It("gives the same rank to players with the same points", func() {
+		Expect(game.Leaderboard()[0].Rank).To(Equal(1))
+		Expect(game.Leaderboard()[1].Rank).To(Equal(1))
+	})
+})
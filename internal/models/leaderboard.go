@@ -0,0 +1,59 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// CompletedLeaderboard returns the completed sessions ranked with RankSessions.
+// Disqualified sessions are left out.
+func CompletedLeaderboard(db *gorm.DB) ([]Session, error) {
+	sessions := []Session{}
+	if err := db.Where("complete = ? AND disqualified = ?", true, false).Find(&sessions).Error; err != nil {
+		return sessions, fmt.Errorf("looking up completed sessions: %w", err)
+	}
+
+	return RankSessions(sessions), nil
+}
+
+// RankSessions sorts the sessions by score (highest first), then by total
+// answer time (fastest first), then by completion time (earliest first) and
+// sets their Rank. Sessions that are equal in all three share the same rank
+// (e.g. 1, 2, 2, 4).
+func RankSessions(sessions []Session) []Session {
+	sort.SliceStable(sessions, func(i, j int) bool {
+		a, b := sessions[i], sessions[j]
+		if !sameRank(a, b) {
+			return rankedHigher(a, b)
+		}
+		return a.ID < b.ID // just to keep a stable order for display
+	})
+
+	for i := range sessions {
+		if i > 0 && sameRank(sessions[i-1], sessions[i]) {
+			sessions[i].Rank = sessions[i-1].Rank
+		} else {
+			sessions[i].Rank = i + 1
+		}
+	}
+
+	return sessions
+}
+
+func rankedHigher(a, b Session) bool {
+	if a.RankingScore() != b.RankingScore() {
+		return a.RankingScore() > b.RankingScore()
+	}
+	if a.TotalAnswerTime != b.TotalAnswerTime {
+		return a.TotalAnswerTime < b.TotalAnswerTime
+	}
+	return a.CompletedAt.Before(b.CompletedAt)
+}
+
+func sameRank(a, b Session) bool {
+	return a.RankingScore() == b.RankingScore() &&
+		a.TotalAnswerTime == b.TotalAnswerTime &&
+		a.CompletedAt.Equal(b.CompletedAt)
+}
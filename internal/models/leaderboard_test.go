@@ -0,0 +1,87 @@
+package models_test
+
+import (
+	"time"
+
+	. "github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Leaderboard", func() {
+	Describe("RankSessions", func() {
+		It("breaks ties by total answer time, then by completion time", func() {
+			now := time.Now()
+			sessions := []Session{
+				{Nickname: "slow", Score: 80, TotalAnswerTime: 2 * time.Minute, CompletedAt: now},
+				{Nickname: "best", Score: 90, TotalAnswerTime: 5 * time.Minute, CompletedAt: now},
+				{Nickname: "fast-late", Score: 80, TotalAnswerTime: time.Minute, CompletedAt: now.Add(time.Hour)},
+				{Nickname: "fast-early", Score: 80, TotalAnswerTime: time.Minute, CompletedAt: now},
+			}
+
+			ranked := RankSessions(sessions)
+			nicknames := []string{}
+			for _, s := range ranked {
+				nicknames = append(nicknames, s.Nickname)
+			}
+			Expect(nicknames).To(HaveExactElements("best", "fast-early", "fast-late", "slow"))
+			Expect(ranked[3].Rank).To(Equal(4))
+		})
+
+		It("gives the same rank to sessions that are equal in everything", func() {
+			now := time.Now()
+			sessions := []Session{
+				{Nickname: "a", Score: 100, CompletedAt: now},
+				{Nickname: "b", Score: 80, CompletedAt: now},
+				{Nickname: "c", Score: 80, CompletedAt: now},
+				{Nickname: "d", Score: 50, CompletedAt: now},
+			}
+
+			ranks := []int{}
+			for _, s := range RankSessions(sessions) {
+				ranks = append(ranks, s.Rank)
+			}
+			Expect(ranks).To(HaveExactElements(1, 2, 2, 4))
+		})
+	})
+
+	Describe("CompletedLeaderboard", func() {
+		It("leaves out disqualified sessions", func() {
+			for _, s := range []Session{
+				{Email: "cheater@example.com", Score: 100, Complete: true, Disqualified: true},
+				{Email: "alice@example.com", Score: 80, Complete: true},
+				{Email: "bob@example.com", Score: 90},
+			} {
+				Expect(db.Create(&s).Error).ToNot(HaveOccurred())
+			}
+
+			sessions, err := CompletedLeaderboard(db)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sessions).To(HaveLen(1))
+			Expect(sessions[0].Email).To(Equal("alice@example.com"))
+			Expect(sessions[0].Rank).To(Equal(1))
+		})
+	})
+
+	Describe("PrizeList#ForRank", func() {
+		It("returns the prize mapped to the rank", func() {
+			prizes := PrizeList{
+				{Title: "Gold", Rank: 1},
+				{Title: "Silver", Ranks: "2-3"},
+				{Title: "Unranked"},
+			}
+
+			p, found := prizes.ForRank(1)
+			Expect(found).To(BeTrue())
+			Expect(p.Title).To(Equal("Gold"))
+
+			p, found = prizes.ForRank(3)
+			Expect(found).To(BeTrue())
+			Expect(p.Title).To(Equal("Silver"))
+			Expect(p.RankLabel()).To(Equal("#2-3"))
+
+			_, found = prizes.ForRank(4)
+			Expect(found).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Lease gives one of the replicas of the server the right to do something
+// (e.g. to sweep the sessions) until it expires, so that the replicas don't
+// do the same work at the same time. The holder renews it to keep it.
+type Lease struct {
+	Name      string `gorm:"primaryKey"`
+	Holder    string
+	ExpiresAt time.Time
+}
+
+// AcquireLease takes (or renews) the lease with the given name for the holder
+// until now + ttl, unless another holder has it and it hasn't expired yet.
+// It returns whether the holder has the lease.
+func AcquireLease(db *gorm.DB, name, holder string, ttl time.Duration, now time.Time) (bool, error) {
+	err := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&Lease{Name: name, Holder: holder, ExpiresAt: now.Add(ttl)}).Error
+	if err != nil {
+		return false, fmt.Errorf("creating lease %s: %w", name, err)
+	}
+
+	// a single statement, so that only one of the replicas can win
+	result := db.Model(&Lease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, holder, now).
+		Updates(map[string]interface{}{"holder": holder, "expires_at": now.Add(ttl)})
+	if result.Error != nil {
+		return false, fmt.Errorf("acquiring lease %s: %w", name, result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,54 @@
+package models_test
+
+import (
+	"time"
+
+	. "github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AcquireLease", func() {
+	var now time.Time
+
+	BeforeEach(func() {
+		now = time.Now()
+	})
+
+	It("gives the lease to one holder until it expires", func() {
+		held, err := AcquireLease(db, "sweeper", "replica-1", time.Minute, now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(held).To(BeTrue())
+
+		held, err = AcquireLease(db, "sweeper", "replica-2", time.Minute, now.Add(30*time.Second))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(held).To(BeFalse())
+
+		// the holder renews it
+		held, err = AcquireLease(db, "sweeper", "replica-1", time.Minute, now.Add(45*time.Second))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(held).To(BeTrue())
+
+		held, err = AcquireLease(db, "sweeper", "replica-2", time.Minute, now.Add(90*time.Second))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(held).To(BeFalse())
+
+		// and loses it when it doesn't
+		held, err = AcquireLease(db, "sweeper", "replica-2", time.Minute, now.Add(2*time.Minute))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(held).To(BeTrue())
+		held, err = AcquireLease(db, "sweeper", "replica-1", time.Minute, now.Add(2*time.Minute))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(held).To(BeFalse())
+	})
+
+	It("keeps the leases apart", func() {
+		held, err := AcquireLease(db, "sweeper", "replica-1", time.Minute, now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(held).To(BeTrue())
+
+		held, err = AcquireLease(db, "other", "replica-2", time.Minute, now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(held).To(BeTrue())
+	})
+})
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrNoMigrationApplied = errors.New("no migration has been applied")
+
+// Migration is a versioned change of the schema (and the data). Migrations
+// are compiled into the binary (see migrations) and applied in the order of
+// their Version. They must not use the models, which change over time, but
+// snapshots of them as they were at that Version.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration records an applied Migration
+type SchemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// MigrationStatus tells whether a Migration has been applied to a database
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// MigrationResult is what MigrateUp did
+type MigrationResult struct {
+	Applied []Migration
+	// Adopted is true when the database had been created by AutoMigrate,
+	// before versioned migrations were introduced. Its schema is brought up to
+	// the baseline (the first migration) instead of being created.
+	Adopted bool
+}
+
+// Migrations returns the migrations of the binary, sorted by version
+func Migrations() []Migration {
+	result := append([]Migration{}, migrations...)
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+
+	return result
+}
+
+// MigrateUp applies the pending migrations in order, each one in its own
+// transaction.
+func MigrateUp(db *gorm.DB) (MigrationResult, error) {
+	result := MigrationResult{}
+
+	// The tables of the baseline exist without a record of it: the database
+	// was created with AutoMigrate.
+	result.Adopted = !db.Migrator().HasTable(&SchemaMigration{}) && db.Migrator().HasTable("sessions")
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return result, fmt.Errorf("creating the schema_migrations table: %w", err)
+	}
+
+	statuses, err := MigrationStatuses(db)
+	if err != nil {
+		return result, err
+	}
+	for _, s := range statuses {
+		if s.Applied {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := s.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: s.Version, Name: s.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return result, fmt.Errorf("applying migration %d (%s): %w", s.Version, s.Name, err)
+		}
+		result.Applied = append(result.Applied, s.Migration)
+	}
+
+	return result, nil
+}
+
+// MigrateDown rolls back the latest applied migration and returns it
+func MigrateDown(db *gorm.DB) (Migration, error) {
+	statuses, err := MigrationStatuses(db)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		s := statuses[i]
+		if !s.Applied {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := s.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, s.Version).Error
+		})
+		if err != nil {
+			return s.Migration, fmt.Errorf("rolling back migration %d (%s): %w", s.Version, s.Name, err)
+		}
+		return s.Migration, nil
+	}
+
+	return Migration{}, ErrNoMigrationApplied
+}
+
+// MigrationStatuses returns all the migrations of the binary, and whether
+// they have been applied
+func MigrationStatuses(db *gorm.DB) ([]MigrationStatus, error) {
+	applied := map[int]SchemaMigration{}
+	if db.Migrator().HasTable(&SchemaMigration{}) {
+		records := []SchemaMigration{}
+		if err := db.Find(&records).Error; err != nil {
+			return nil, fmt.Errorf("looking up the applied migrations: %w", err)
+		}
+		for _, r := range records {
+			applied[r.Version] = r
+		}
+	}
+
+	result := []MigrationStatus{}
+	for _, m := range Migrations() {
+		record, found := applied[m.Version]
+		result = append(result, MigrationStatus{Migration: m, Applied: found, AppliedAt: record.AppliedAt})
+	}
+
+	return result, nil
+}
+
+// PendingMigrations returns the migrations that haven't been applied
+func PendingMigrations(db *gorm.DB) ([]Migration, error) {
+	statuses, err := MigrationStatuses(db)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Migration{}
+	for _, s := range statuses {
+		if !s.Applied {
+			result = append(result, s.Migration)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,179 @@
+package models_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Migrations", func() {
+	// the suite has already migrated the database
+	It("records the applied migrations", func() {
+		statuses, err := MigrationStatuses(db)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(statuses).To(HaveLen(len(Migrations())))
+		for _, s := range statuses {
+			Expect(s.Applied).To(BeTrue(), "migration %d", s.Version)
+		}
+
+		result, err := MigrateUp(db)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Applied).To(BeEmpty())
+		Expect(result.Adopted).To(BeFalse())
+	})
+
+	It("rolls back and reapplies all the migrations", func() {
+		for range Migrations() {
+			_, err := MigrateDown(db)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		_, err := MigrateDown(db)
+		Expect(err).To(MatchError(ErrNoMigrationApplied))
+		Expect(db.Migrator().HasTable("sessions")).To(BeFalse())
+
+		pending, err := PendingMigrations(db)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pending).To(HaveLen(len(Migrations())))
+
+		result, err := MigrateUp(db)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Applied).To(HaveLen(len(Migrations())))
+		_, err = NewSession(db, "alice@example.com", "alice")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("stores the times that aren't set as NULL", func() {
+		// roll back to before nullable_times
+		for {
+			migration, err := MigrateDown(db)
+			Expect(err).ToNot(HaveOccurred())
+			if migration.Name == "nullable_times" {
+				break
+			}
+		}
+		err := db.Exec("INSERT INTO sessions (email, created_at, completed_at) VALUES (?, ?, ?)",
+			"alice@example.com", time.Now(), time.Time{}).Error
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = MigrateUp(db)
+		Expect(err).ToNot(HaveOccurred())
+
+		var zero int64
+		Expect(db.Raw("SELECT COUNT(*) FROM sessions WHERE completed_at IS NULL").Scan(&zero).Error).ToNot(HaveOccurred())
+		Expect(zero).To(Equal(int64(1)))
+		session, err := SessionForEmail(db, "alice@example.com")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(session.CompletedAt.IsZero()).To(BeTrue())
+
+		// and they stay NULL
+		Expect(session.SaveCacheColumns(db)).To(Succeed())
+		_, err = NewSession(db, "bob@example.com", "bob")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(db.Raw("SELECT COUNT(*) FROM sessions WHERE completed_at IS NULL").Scan(&zero).Error).ToNot(HaveOccurred())
+		Expect(zero).To(Equal(int64(2)))
+	})
+
+	It("migrates a SQLite database created by AutoMigrate", func() {
+		// created by the version before migrations, with the constraints
+		// AutoMigrate added on session_email
+		fixture, err := os.ReadFile(filepath.Join("..", "..", "tests", "assets", "automigrated_database.sql"))
+		Expect(err).ToNot(HaveOccurred())
+		path := filepath.Join(GinkgoT().TempDir(), "database.sql")
+		Expect(os.WriteFile(path, fixture, 0600)).To(Succeed())
+
+		legacy, err := OpenDatabase(path)
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(func() {
+			sqlDB, err := legacy.DB()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sqlDB.Close()).To(Succeed())
+		})
+
+		result, err := MigrateUp(legacy)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Adopted).To(BeTrue())
+
+		var session Session
+		Expect(legacy.Preload("Questions").First(&session, "email = ?", "john.doe@example.com").Error).ToNot(HaveOccurred())
+		Expect(session.Questions).To(HaveLen(1))
+	})
+
+	Describe("on a database created by AutoMigrate", func() {
+		var alice, bob int64
+
+		// insertSession inserts a session in the baseline schema
+		insertSession := func(email, nickname string) int64 {
+			err := db.Exec("INSERT INTO sessions (email, nickname, created_at) VALUES (?, ?, ?)", email, nickname, time.Now()).Error
+			Expect(err).ToNot(HaveOccurred())
+			var id int64
+			Expect(db.Raw("SELECT MAX(id) FROM sessions").Scan(&id).Error).ToNot(HaveOccurred())
+			return id
+		}
+
+		BeforeEach(func() {
+			// roll back to the baseline and forget about migrations
+			for range Migrations()[1:] {
+				_, err := MigrateDown(db)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(db.Migrator().DropTable(&SchemaMigration{})).To(Succeed())
+
+			alice = insertSession("alice@example.com", "alice")
+			insertSession("alice@example.com", "alice again") // created by a concurrent request
+			bob = insertSession("bob@example.com", "bob")
+			for _, email := range []string{"alice@example.com", "alice@example.com", "bob@example.com"} {
+				err := db.Exec("INSERT INTO questions (session_email, text, created_at) VALUES (?, ?, ?)", email, "a question", time.Now()).Error
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+
+		It("adopts it as the baseline", func() {
+			result, err := MigrateUp(db)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Adopted).To(BeTrue())
+			Expect(result.Applied).To(HaveLen(len(Migrations())))
+
+			session, err := SessionForEmail(db, "bob@example.com")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(session.ID).To(BeEquivalentTo(bob))
+		})
+
+		It("moves the questions of duplicate sessions to the oldest one", func() {
+			_, err := MigrateUp(db)
+			Expect(err).ToNot(HaveOccurred())
+
+			sessions := []Session{}
+			Expect(db.Preload("Questions").Order("id").Find(&sessions).Error).ToNot(HaveOccurred())
+			Expect(sessions).To(HaveLen(2))
+			Expect(sessions[0].ID).To(BeEquivalentTo(alice))
+			Expect(sessions[0].Questions).To(HaveLen(2))
+			Expect(sessions[1].ID).To(BeEquivalentTo(bob))
+			Expect(sessions[1].Questions).To(HaveLen(1))
+
+			_, err = NewSession(db, "alice@example.com", "alice")
+			Expect(err).To(MatchError(ErrEmailUsed))
+		})
+
+		It("recalculates the score of the merged sessions", func() {
+			// the first question of alice was answered right, but the score
+			// was saved on the session that gets deleted
+			now := time.Now()
+			err := db.Exec("UPDATE questions SET right_answer = 1, user_answer = 1, answers = ?, started_at = ?, answered_at = ? "+
+				"WHERE id = (SELECT MIN(id) FROM questions)", []byte(`["a","b"]`), now.Add(-time.Second), now).Error
+			Expect(err).ToNot(HaveOccurred())
+			Expect(db.Exec("UPDATE sessions SET score = 100 WHERE nickname = ?", "alice again").Error).To(Succeed())
+
+			_, err = MigrateUp(db)
+			Expect(err).ToNot(HaveOccurred())
+
+			session, err := SessionForEmail(db, "alice@example.com")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(session.Score).To(Equal(100)) // of the answered questions
+			Expect(session.Complete).To(BeFalse())
+		})
+	})
+})
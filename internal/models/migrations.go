@@ -0,0 +1,397 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrations are the Migrations of the schema. Append new ones with the next
+// Version, never change the ones that have been released.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		// AutoMigrate creates the tables, or adds what's missing to the tables
+		// of a database created by AutoMigrate (see MigrationResult.Adopted).
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(baselineTables()...)
+		},
+		Down: func(tx *gorm.DB) error {
+			tables := baselineTables()
+			for i := len(tables) - 1; i >= 0; i-- {
+				if err := tx.Migrator().DropTable(tables[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 2,
+		Name:    "session_id_foreign_key",
+		// Questions referenced their session by email, which wasn't unique:
+		// concurrent requests could create several sessions for an email and
+		// all of them got all the questions. The questions are moved to the
+		// oldest of them, the rest are deleted and the cached columns of the
+		// oldest are recalculated (they may have been saved on another one).
+		Up: func(tx *gorm.DB) error {
+			var merged []uint
+			err := tx.Raw("SELECT MIN(id) FROM sessions GROUP BY email HAVING COUNT(*) > 1").Scan(&merged).Error
+			if err != nil {
+				return fmt.Errorf("looking up duplicate sessions: %w", err)
+			}
+			if err := tx.Migrator().AddColumn(&questionV2{}, "SessionID"); err != nil {
+				return err
+			}
+			err = tx.Exec("UPDATE questions SET session_id = " +
+				"(SELECT MIN(sessions.id) FROM sessions WHERE sessions.email = questions.session_email)").Error
+			if err != nil {
+				return fmt.Errorf("setting the session of the questions: %w", err)
+			}
+			// the nested select lets MySQL delete from the table it selects from
+			err = tx.Exec("DELETE FROM sessions WHERE id NOT IN " +
+				"(SELECT id FROM (SELECT MIN(id) AS id FROM sessions GROUP BY email) AS oldest)").Error
+			if err != nil {
+				return fmt.Errorf("deleting duplicate sessions: %w", err)
+			}
+			// AutoMigrate created (unenforced) constraints on session_email in
+			// SQLite databases
+			for _, name := range []string{"fk_questions_session", "fk_sessions_questions"} {
+				if !tx.Migrator().HasConstraint(&baselineQuestion{}, name) {
+					continue
+				}
+				if err := tx.Migrator().DropConstraint(&baselineQuestion{}, name); err != nil {
+					return err
+				}
+			}
+			if err := tx.Migrator().DropColumn(&baselineQuestion{}, "SessionEmail"); err != nil {
+				return err
+			}
+
+			// creates the unique index, the foreign key and the indexes SQLite
+			// drops when it recreates a table to drop a column
+			if err := tx.AutoMigrate(&sessionV2{}, &questionV2{}); err != nil {
+				return err
+			}
+
+			// the models only read the columns that exist, and only the
+			// cached columns (which exist since the baseline) are written
+			for _, id := range merged {
+				var session Session
+				if err := tx.Preload("Questions").First(&session, id).Error; err != nil {
+					return fmt.Errorf("looking up session %d: %w", id, err)
+				}
+				session.UpdateCacheColumns()
+				if err := session.SaveCacheColumns(tx); err != nil {
+					return fmt.Errorf("updating session %s: %w", session.Email, err)
+				}
+			}
+
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&baselineQuestion{}, "SessionEmail"); err != nil {
+				return err
+			}
+			err := tx.Exec("UPDATE questions SET session_email = " +
+				"(SELECT sessions.email FROM sessions WHERE sessions.id = questions.session_id)").Error
+			if err != nil {
+				return fmt.Errorf("setting the session email of the questions: %w", err)
+			}
+			if err := tx.Migrator().DropConstraint(&questionV2{}, "Session"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropIndex(&sessionV2{}, "Email"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropColumn(&questionV2{}, "SessionID"); err != nil {
+				return err
+			}
+
+			return tx.AutoMigrate(&baselineSession{}, &baselineQuestion{})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "void_decisions",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&voidDecisionV3{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&voidDecisionV3{})
+		},
+	},
+	{
+		Version: 4,
+		Name:    "text_answer_columns",
+		// The answers are stored as JSON, which can be longer than 255
+		// characters. SQLite doesn't enforce the length (and can't alter
+		// columns without recreating the table).
+		Up: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() == "sqlite" {
+				return nil
+			}
+			for _, field := range []string{"RightAnswers", "UserAnswers", "AcceptedAnswers", "Answers"} {
+				if err := tx.Migrator().AlterColumn(&questionAnswersV4{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() == "sqlite" {
+				return nil
+			}
+			for _, field := range []string{"RightAnswers", "UserAnswers", "AcceptedAnswers", "Answers"} {
+				if err := tx.Migrator().AlterColumn(&questionAnswersV2{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 5,
+		Name:    "nullable_times",
+		// Times that aren't set (e.g. the answer time of a question that
+		// wasn't answered) were stored as zero times, which MySQL rejects in
+		// strict mode. They are NULL now (see nullTimeSerializer).
+		Up: func(tx *gorm.DB) error {
+			// the zero time is 0001-01-01, or 0000-00-00 in MySQL
+			before := time.Date(1000, 1, 1, 0, 0, 0, 0, time.UTC)
+			for table, columns := range nullableTimesV5 {
+				for _, column := range columns {
+					err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s = NULL WHERE %s < ?", table, column, column), before).Error
+					if err != nil {
+						return fmt.Errorf("clearing the zero times of %s.%s: %w", table, column, err)
+					}
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for table, columns := range nullableTimesV5 {
+				for _, column := range columns {
+					err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s IS NULL", table, column, column), time.Time{}).Error
+					if err != nil {
+						return fmt.Errorf("restoring the zero times of %s.%s: %w", table, column, err)
+					}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 6,
+		Name:    "change_notifications_and_leases",
+		// for running several replicas of the server
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&changeNotificationV6{}, &leaseV6{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&leaseV6{}, &changeNotificationV6{})
+		},
+	},
+}
+
+// The models as they were when AutoMigrate was replaced by migrations
+type (
+	baselineScoring struct {
+		Mode                string
+		PointsPerDifficulty int
+		SpeedBonus          float64
+	}
+
+	baselineSession struct {
+		gorm.Model
+		Email           string
+		Nickname        string
+		Score           int
+		Points          int
+		Scoring         baselineScoring `gorm:"embedded;embeddedPrefix:scoring_"`
+		Complete        bool
+		Disqualified    bool
+		TotalAnswerTime time.Duration
+		CompletedAt     time.Time
+	}
+
+	baselineQuestion struct {
+		gorm.Model
+		Index           int
+		PoolID          string `gorm:"index"`
+		SessionEmail    string
+		Text            string
+		Difficulty      int
+		Type            string
+		Category        string
+		RightAnswer     int
+		UserAnswer      int
+		RightAnswers    string `gorm:"type:VARCHAR(255)"`
+		UserAnswers     string `gorm:"type:VARCHAR(255)"`
+		Scoring         string
+		AcceptedAnswers string `gorm:"type:VARCHAR(255)"`
+		AnswerPattern   string
+		CaseSensitive   bool
+		Tolerance       float64
+		UserTextAnswer  string
+		Answers         string `gorm:"type:VARCHAR(255)"`
+		AllowedSeconds  int
+		Source          string
+		Voided          bool
+		StartedAt       time.Time
+		AnsweredAt      time.Time
+		ExpiredAt       time.Time
+	}
+
+	baselineGame struct {
+		gorm.Model
+		Code      string `gorm:"uniqueIndex"`
+		HostToken string
+		State     string
+		Current   int
+		Scoring   baselineScoring `gorm:"embedded;embeddedPrefix:scoring_"`
+	}
+
+	baselineGameQuestion struct {
+		gorm.Model
+		GameID     uint `gorm:"index"`
+		Index      int
+		Question   string // JSON
+		StartedAt  time.Time
+		RevealedAt time.Time
+	}
+
+	baselineGamePlayer struct {
+		gorm.Model
+		GameID   uint `gorm:"index"`
+		Nickname string
+		Token    string
+		Points   int
+	}
+
+	baselineGameAnswer struct {
+		gorm.Model
+		GameQuestionID uint `gorm:"uniqueIndex:idx_game_answers_question_player"`
+		GamePlayerID   uint `gorm:"uniqueIndex:idx_game_answers_question_player"`
+		Answer         int
+		AnsweredAt     time.Time
+		Points         int
+	}
+)
+
+// The models of version 2, with a SessionID foreign key in the questions
+type (
+	sessionV2 struct {
+		gorm.Model
+		Email           string `gorm:"uniqueIndex"`
+		Nickname        string
+		Score           int
+		Points          int
+		Scoring         baselineScoring `gorm:"embedded;embeddedPrefix:scoring_"`
+		Complete        bool
+		Disqualified    bool
+		TotalAnswerTime time.Duration
+		CompletedAt     time.Time
+	}
+
+	questionV2 struct {
+		gorm.Model
+		Index           int
+		PoolID          string `gorm:"index"`
+		SessionID       uint   `gorm:"index"`
+		Session         sessionV2
+		Text            string
+		Difficulty      int
+		Type            string
+		Category        string
+		RightAnswer     int
+		UserAnswer      int
+		RightAnswers    string `gorm:"type:VARCHAR(255)"`
+		UserAnswers     string `gorm:"type:VARCHAR(255)"`
+		Scoring         string
+		AcceptedAnswers string `gorm:"type:VARCHAR(255)"`
+		AnswerPattern   string
+		CaseSensitive   bool
+		Tolerance       float64
+		UserTextAnswer  string
+		Answers         string `gorm:"type:VARCHAR(255)"`
+		AllowedSeconds  int
+		Source          string
+		Voided          bool
+		StartedAt       time.Time
+		AnsweredAt      time.Time
+		ExpiredAt       time.Time
+	}
+)
+
+func (sessionV2) TableName() string  { return "sessions" }
+func (questionV2) TableName() string { return "questions" }
+
+// The model of version 3, the void decisions of the admins
+type voidDecisionV3 struct {
+	PoolID    string `gorm:"primaryKey"`
+	Voided    bool
+	UpdatedAt time.Time
+}
+
+func (voidDecisionV3) TableName() string { return "void_decisions" }
+
+// The JSON columns of the questions, before and after version 4
+type (
+	questionAnswersV2 struct {
+		RightAnswers    string `gorm:"type:VARCHAR(255)"`
+		UserAnswers     string `gorm:"type:VARCHAR(255)"`
+		AcceptedAnswers string `gorm:"type:VARCHAR(255)"`
+		Answers         string `gorm:"type:VARCHAR(255)"`
+	}
+
+	questionAnswersV4 struct {
+		RightAnswers    string `gorm:"type:TEXT"`
+		UserAnswers     string `gorm:"type:TEXT"`
+		AcceptedAnswers string `gorm:"type:TEXT"`
+		Answers         string `gorm:"type:TEXT"`
+	}
+)
+
+func (questionAnswersV2) TableName() string { return "questions" }
+func (questionAnswersV4) TableName() string { return "questions" }
+
+// The time columns that are NULL when not set since version 5, per table
+var nullableTimesV5 = map[string][]string{
+	"sessions":       {"completed_at"},
+	"questions":      {"started_at", "answered_at", "expired_at"},
+	"game_questions": {"started_at", "revealed_at"},
+	"game_answers":   {"answered_at"},
+}
+
+func (baselineSession) TableName() string      { return "sessions" }
+func (baselineQuestion) TableName() string     { return "questions" }
+func (baselineGame) TableName() string         { return "games" }
+func (baselineGameQuestion) TableName() string { return "game_questions" }
+func (baselineGamePlayer) TableName() string   { return "game_players" }
+func (baselineGameAnswer) TableName() string   { return "game_answers" }
+
+func baselineTables() []interface{} {
+	return []interface{}{&baselineSession{}, &baselineQuestion{}, &baselineGame{},
+		&baselineGameQuestion{}, &baselineGamePlayer{}, &baselineGameAnswer{}}
+}
+
+// The models of version 6, for running several replicas of the server
+type (
+	changeNotificationV6 struct {
+		Topic     string `gorm:"primaryKey"`
+		Version   int64
+		UpdatedAt time.Time
+	}
+
+	leaseV6 struct {
+		Name      string `gorm:"primaryKey"`
+		Holder    string
+		ExpiresAt time.Time
+	}
+)
+
+func (changeNotificationV6) TableName() string { return "change_notifications" }
+func (leaseV6) TableName() string              { return "leases" }
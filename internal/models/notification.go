@@ -0,0 +1,97 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChangeNotification records that something that is pushed to the browsers
+// (e.g. the leaderboard or a live game) changed, so that every replica of the
+// server can push it to its own clients (see NotificationRelay). The events
+// carry the whole current state, so the version is all the replicas need.
+type ChangeNotification struct {
+	Topic     string `gorm:"primaryKey"`
+	Version   int64
+	UpdatedAt time.Time
+}
+
+// NotifyChange bumps the version of the topic
+func NotifyChange(db *gorm.DB, topic string) error {
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "topic"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"version": gorm.Expr("change_notifications.version + 1"), "updated_at": time.Now()}),
+	}).Create(&ChangeNotification{Topic: topic, Version: 1}).Error
+	if err != nil {
+		return fmt.Errorf("notifying a change of %s: %w", topic, err)
+	}
+
+	return nil
+}
+
+// ChangeVersions returns the current version of every topic
+func ChangeVersions(db *gorm.DB) (map[string]int64, error) {
+	var notifications []ChangeNotification
+	if err := db.Find(&notifications).Error; err != nil {
+		return nil, fmt.Errorf("looking up change notifications: %w", err)
+	}
+
+	result := map[string]int64{}
+	for _, n := range notifications {
+		result[n.Topic] = n.Version
+	}
+
+	return result, nil
+}
+
+// NotificationRelay checks the change notifications periodically (see Run)
+// and calls OnChange for every topic that changed, on this replica or on
+// another one.
+type NotificationRelay struct {
+	DB          *gorm.DB
+	Interval    time.Duration
+	ErrorLogger *log.Logger
+	OnChange    func(topic string)
+}
+
+// Run checks the notifications every `Interval` until the context is done.
+// The changes made before it started are skipped. It returns right away when
+// `Interval` is 0.
+func (r NotificationRelay) Run(ctx context.Context) {
+	if r.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	db := r.DB.WithContext(ctx)
+	known, err := ChangeVersions(db)
+	if err != nil {
+		r.ErrorLogger.Printf("relaying changes: %s\n", err.Error())
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current, err := ChangeVersions(db)
+			if err != nil {
+				if ctx.Err() == nil {
+					r.ErrorLogger.Printf("relaying changes: %s\n", err.Error())
+				}
+				continue
+			}
+			// nothing is known when the first look up failed
+			for topic, version := range current {
+				if known != nil && known[topic] != version {
+					r.OnChange(topic)
+				}
+			}
+			known = current
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package models_test
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	. "github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Change notifications", func() {
+	It("bumps the version of the topic", func() {
+		Expect(NotifyChange(db, "leaderboard")).To(Succeed())
+		Expect(NotifyChange(db, "leaderboard")).To(Succeed())
+		Expect(NotifyChange(db, "game:ABCD")).To(Succeed())
+
+		versions, err := ChangeVersions(db)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(versions).To(Equal(map[string]int64{"leaderboard": 2, "game:ABCD": 1}))
+	})
+
+	Describe("NotificationRelay", func() {
+		It("relays the changes made after it started", func() {
+			Expect(NotifyChange(db, "leaderboard")).To(Succeed())
+
+			var mu sync.Mutex
+			changed := []string{}
+			relay := NotificationRelay{
+				DB:          db,
+				Interval:    10 * time.Millisecond,
+				ErrorLogger: log.New(GinkgoWriter, "", 0),
+				OnChange: func(topic string) {
+					mu.Lock()
+					defer mu.Unlock()
+					changed = append(changed, topic)
+				},
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				relay.Run(ctx)
+				close(done)
+			}()
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			relayed := func() []string {
+				mu.Lock()
+				defer mu.Unlock()
+				return append([]string{}, changed...)
+			}
+			Consistently(relayed, 50*time.Millisecond).Should(BeEmpty())
+
+			Expect(NotifyChange(db, "game:ABCD")).To(Succeed())
+			Eventually(relayed).Should(Equal([]string{"game:ABCD"}))
+			Expect(NotifyChange(db, "leaderboard")).To(Succeed())
+			Eventually(relayed).Should(Equal([]string{"game:ABCD", "leaderboard"}))
+		})
+	})
+})
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("nulltime", nullTimeSerializer{})
+}
+
+// nullTimeSerializer stores the zero time.Time as NULL and reads NULL back as
+// the zero time, so that the models can keep using IsZero. MySQL rejects zero
+// dates in its default (strict) mode. Use it with `gorm:"serializer:nulltime"`
+// and nullTime for the columns set with Update or Updates.
+type nullTimeSerializer struct{}
+
+// nullTimeLayouts are the layouts of the times that drivers return as text
+// (e.g. SQLite for the zero times stored before they became NULL)
+var nullTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+func (nullTimeSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var t time.Time
+	switch v := dbValue.(type) {
+	case nil:
+	case time.Time:
+		t = v
+	case []byte:
+		var err error
+		if t, err = parseNullTime(string(v)); err != nil {
+			return err
+		}
+	case string:
+		var err error
+		if t, err = parseNullTime(v); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported time value %v (%T)", dbValue, dbValue)
+	}
+
+	return field.Set(ctx, dst, t)
+}
+
+func (nullTimeSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	if t, ok := fieldValue.(time.Time); ok {
+		return nullTime(t), nil
+	}
+
+	return nil, fmt.Errorf("unsupported time value %v (%T)", fieldValue, fieldValue)
+}
+
+// nullTime returns nil for the zero time, see nullTimeSerializer
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t
+}
+
+func parseNullTime(value string) (time.Time, error) {
+	if strings.HasPrefix(value, "0000-00-00") {
+		return time.Time{}, nil
+	}
+	for _, layout := range nullTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unsupported time value %q", value)
+}
@@ -5,53 +5,411 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+var (
+	ErrQuestionVoided     = errors.New("the question was removed from the quiz")
+	ErrQuestionAnswered   = errors.New("the question has already been answered")
+	ErrQuestionNotStarted = errors.New("the question hasn't been asked yet")
+	ErrQuestionExpired    = errors.New("the time to answer the question ran out")
+)
+
 // https://raaaaaaaay86.medium.com/how-to-store-plain-string-slice-by-using-gorm-f855602013e6
 type (
 	Answers      []string
 	QuestionType string
+	ScoringMode  string
+	// AnswerSet is a set of 1-based answer indices
+	AnswerSet []int
+	// QuestionStatus is the state of a question, see Question.Status
+	QuestionStatus string
+)
+
+const (
+	SingleChoice   QuestionType = "single-choice"
+	MultipleChoice QuestionType = "multiple-choice"
+	Boolean        QuestionType = "boolean"
+	// TextAnswer questions are answered by typing the answer. The answer is
+	// compared to AcceptedAnswers (and AnswerPattern if set) after normalising
+	// case and whitespace.
+	TextAnswer QuestionType = "text"
+	// NumericAnswer questions are answered by typing a number which is
+	// compared to AcceptedAnswers allowing for Tolerance.
+	NumericAnswer QuestionType = "numeric"
+
+	// AllOrNothingScoring counts a multiple-choice answer as correct only when
+	// exactly the right answers are selected. It's the default.
+	AllOrNothingScoring ScoringMode = "all-or-nothing"
+	// PartialScoring gives a fraction of the credit for every right answer
+	// selected and removes the same fraction for every wrong one.
+	PartialScoring ScoringMode = "partial"
+
+	QuestionPending  QuestionStatus = "pending"
+	QuestionStarted  QuestionStatus = "started"
+	QuestionAnswered QuestionStatus = "answered"
+	QuestionExpired  QuestionStatus = "expired"
+	QuestionVoided   QuestionStatus = "voided"
 )
 
 type Question struct {
 	gorm.Model
-	Index          int // used for sorting in the final quiz
-	SessionEmail   string
-	Session        Session      `gorm:"foreignKey:SessionEmail"`
-	Text           string       `yaml:"text,omitempty"`
-	Difficulty     int          `yaml:"difficulty,omitempty"`
-	Type           QuestionType `yaml:"type,omitempty"`
-	RightAnswer    int          `yaml:"rightAnswer,omitempty"`
-	UserAnswer     int          `yaml:"userAnswer,omitempty"`
-	Answers        Answers      `yaml:"answers,omitempty" gorm:"type:VARCHAR(255)"`
-	AllowedSeconds int          `yaml:"allowedSeconds,omitempty"`
-	Source         string       `yaml:"source,omitempty"`
-	StartedAt      time.Time
+	Index           int    // used for sorting in the final quiz
+	PoolID          string `yaml:"id,omitempty" gorm:"index"` // stable identity of the question in the pool
+	SessionID       uint   `gorm:"index"`
+	Session         Session
+	Text            string       `yaml:"text,omitempty"`
+	Difficulty      int          `yaml:"difficulty,omitempty"`
+	Type            QuestionType `yaml:"type,omitempty"`
+	Category        string       `yaml:"category,omitempty"` // usually set per file, see QuestionPool
+	RightAnswer     int          `yaml:"rightAnswer,omitempty"`
+	UserAnswer      int          `yaml:"userAnswer,omitempty"`
+	RightAnswers    AnswerSet    `yaml:"rightAnswers,omitempty" gorm:"type:TEXT"`
+	UserAnswers     AnswerSet    `yaml:"userAnswers,omitempty" gorm:"type:TEXT"`
+	Scoring         ScoringMode  `yaml:"scoring,omitempty"`
+	AcceptedAnswers Answers      `yaml:"acceptedAnswers,omitempty" gorm:"type:TEXT"` // "text" and "numeric" questions
+	AnswerPattern   string       `yaml:"answerPattern,omitempty"`
+	CaseSensitive   bool         `yaml:"caseSensitive,omitempty"`
+	Tolerance       float64      `yaml:"tolerance,omitempty"`
+	UserTextAnswer  string       `yaml:"userTextAnswer,omitempty"` // the raw submitted answer
+	Answers         Answers      `yaml:"answers,omitempty" gorm:"type:TEXT"`
+	AllowedSeconds  int          `yaml:"allowedSeconds,omitempty"`
+	Source          string       `yaml:"source,omitempty"`
+	Voided          bool         `yaml:"voided,omitempty"` // counts for nobody
+	StartedAt       time.Time    `gorm:"serializer:nulltime"`
+	AnsweredAt      time.Time    `gorm:"serializer:nulltime"`
+	// ExpiredAt records when the time ran out (see Session.ExpireQuestions).
+	// Questions of abandoned sessions are expired without being started.
+	ExpiredAt time.Time `gorm:"serializer:nulltime"`
 }
 
 func (q Question) Expired() bool {
+	if !q.ExpiredAt.IsZero() {
+		return !q.Answered()
+	}
+
 	isStarted := !q.StartedAt.IsZero()
-	outOfTime := int(time.Since(q.StartedAt).Seconds()) > q.AllowedSeconds
-	notAnswered := (q.UserAnswer == 0)
+	outOfTime := time.Now().After(q.Deadline())
+	notAnswered := !q.Answered()
 
 	return isStarted && outOfTime && notAnswered
 }
 
+// Status returns the state of the question in the quiz
+func (q Question) Status() QuestionStatus {
+	switch {
+	case q.Voided:
+		return QuestionVoided
+	case q.Answered():
+		return QuestionAnswered
+	case q.Expired():
+		return QuestionExpired
+	case !q.StartedAt.IsZero():
+		return QuestionStarted
+	default:
+		return QuestionPending
+	}
+}
+
+// CheckAnswerAllowed returns an error if an answer submitted at the given
+// time can't be accepted. The grace period is added to the deadline to allow
+// for network latency.
+func (q Question) CheckAnswerAllowed(at time.Time, grace time.Duration) error {
+	switch {
+	case q.Voided:
+		return ErrQuestionVoided
+	case q.Answered():
+		return ErrQuestionAnswered
+	case q.StartedAt.IsZero():
+		return ErrQuestionNotStarted
+	case at.After(q.Deadline().Add(grace)):
+		return ErrQuestionExpired
+	}
+
+	return nil
+}
+
+// Deadline returns when the question expired (or will expire). It's zero
+// for questions that were never started nor expired by the sweeper.
+func (q Question) Deadline() time.Time {
+	if !q.ExpiredAt.IsZero() {
+		return q.ExpiredAt
+	}
+	if q.StartedAt.IsZero() {
+		return time.Time{}
+	}
+
+	return q.StartedAt.Add(time.Duration(q.AllowedSeconds) * time.Second)
+}
+
 func (q Question) Valid() bool {
-	rightAnswerCorrectlySet := q.RightAnswer > 0 && q.RightAnswer <= len(q.Answers)
+	switch q.Type {
+	case TextAnswer:
+		if q.AnswerPattern != "" {
+			if _, err := regexp.Compile(q.AnswerPattern); err != nil {
+				return false
+			}
+		}
+		return len(q.AcceptedAnswers) > 0 || q.AnswerPattern != ""
+	case NumericAnswer:
+		if len(q.AcceptedAnswers) == 0 || q.Tolerance < 0 {
+			return false
+		}
+		for _, a := range q.AcceptedAnswers {
+			if _, err := parseNumber(a); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+
+	correct := q.CorrectAnswers()
+	if len(correct) == 0 {
+		return false
+	}
+	for _, a := range correct {
+		if a < 1 || a > len(q.Answers) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (q Question) IsMultipleChoice() bool {
+	return q.Type == MultipleChoice
+}
+
+// IsFreeInput returns true for questions answered by typing the answer
+// instead of picking one of the Answers.
+func (q Question) IsFreeInput() bool {
+	return q.Type == TextAnswer || q.Type == NumericAnswer
+}
+
+// CorrectAnswers returns the indices of the right answers. Multiple-choice
+// questions that only set `rightAnswer` are treated as having a single
+// right answer.
+func (q Question) CorrectAnswers() AnswerSet {
+	if q.IsMultipleChoice() && len(q.RightAnswers) > 0 {
+		return q.RightAnswers
+	}
+	if q.RightAnswer == 0 {
+		return AnswerSet{}
+	}
+
+	return AnswerSet{q.RightAnswer}
+}
+
+// SubmittedAnswers returns the indices of the answers the user selected
+func (q Question) SubmittedAnswers() AnswerSet {
+	if q.IsMultipleChoice() {
+		return q.UserAnswers
+	}
+	if q.UserAnswer == 0 {
+		return AnswerSet{}
+	}
+
+	return AnswerSet{q.UserAnswer}
+}
+
+func (q Question) Answered() bool {
+	if q.IsFreeInput() {
+		return q.UserTextAnswer != ""
+	}
+
+	return len(q.SubmittedAnswers()) > 0
+}
+
+// Credit returns how much of the question was answered correctly, from 0 to 1.
+func (q Question) Credit() float64 {
+	if q.IsFreeInput() {
+		if q.matchesTextAnswer(q.UserTextAnswer) {
+			return 1
+		}
+		return 0
+	}
+
+	correct := q.CorrectAnswers()
+	submitted := q.SubmittedAnswers()
+	if len(correct) == 0 || len(submitted) == 0 {
+		return 0
+	}
+
+	if q.Scoring != PartialScoring {
+		if correct.Equal(submitted) {
+			return 1
+		}
+		return 0
+	}
 
-	return rightAnswerCorrectlySet
+	hits := 0
+	for _, a := range submitted {
+		if correct.Contains(a) {
+			hits++
+		} else {
+			hits--
+		}
+	}
+	if hits <= 0 {
+		return 0
+	}
+
+	return float64(hits) / float64(len(correct))
+}
+
+func (q Question) Correct() bool {
+	return q.Credit() == 1
+}
+
+// RightAnswerTexts returns the text of the right answers (used by the views)
+func (q Question) RightAnswerTexts() []string {
+	if q.IsFreeInput() {
+		if len(q.AcceptedAnswers) == 0 && q.AnswerPattern != "" {
+			return []string{q.AnswerPattern}
+		}
+		if q.Type == NumericAnswer && q.Tolerance > 0 {
+			result := []string{}
+			for _, a := range q.AcceptedAnswers {
+				result = append(result, fmt.Sprintf("%s (±%s)", a, strconv.FormatFloat(q.Tolerance, 'f', -1, 64)))
+			}
+			return result
+		}
+		return q.AcceptedAnswers
+	}
+
+	return q.answerTexts(q.CorrectAnswers())
+}
+
+// UserAnswerTexts returns the text of the answers the user selected (used by the views)
+func (q Question) UserAnswerTexts() []string {
+	if q.IsFreeInput() {
+		if q.UserTextAnswer == "" {
+			return []string{}
+		}
+		return []string{q.UserTextAnswer}
+	}
+
+	return q.answerTexts(q.SubmittedAnswers())
+}
+
+// matchesTextAnswer checks a typed answer against the accepted answers of a
+// "text" or "numeric" question.
+func (q Question) matchesTextAnswer(answer string) bool {
+	if q.Type == NumericAnswer {
+		value, err := parseNumber(answer)
+		if err != nil {
+			return false
+		}
+		for _, a := range q.AcceptedAnswers {
+			accepted, err := parseNumber(a)
+			if err != nil {
+				continue
+			}
+			if math.Abs(value-accepted) <= q.Tolerance {
+				return true
+			}
+		}
+		return false
+	}
+
+	normalized := q.normalizeText(answer)
+	if normalized == "" {
+		return false
+	}
+	for _, a := range q.AcceptedAnswers {
+		if q.normalizeText(a) == normalized {
+			return true
+		}
+	}
+
+	if q.AnswerPattern != "" {
+		pattern := q.AnswerPattern
+		if !q.CaseSensitive {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false
+		}
+		return re.MatchString(normalized)
+	}
+
+	return false
+}
+
+// normalizeText trims and collapses whitespace and, unless the question is
+// case sensitive, lowercases the text.
+func (q Question) normalizeText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if !q.CaseSensitive {
+		text = strings.ToLower(text)
+	}
+
+	return text
+}
+
+func parseNumber(s string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(s), 64)
+}
+
+func (q Question) answerTexts(set AnswerSet) []string {
+	result := []string{}
+	for _, a := range set {
+		if a > 0 && a <= len(q.Answers) {
+			result = append(result, q.Answers[a-1])
+		}
+	}
+
+	return result
+}
+
+// Contains returns true if the answer index is part of the set
+func (s AnswerSet) Contains(answer int) bool {
+	for _, a := range s {
+		if a == answer {
+			return true
+		}
+	}
+	return false
+}
+
+// Equal returns true when both sets contain the same answers regardless of order
+func (s AnswerSet) Equal(other AnswerSet) bool {
+	for _, a := range s {
+		if !other.Contains(a) {
+			return false
+		}
+	}
+	for _, a := range other {
+		if !s.Contains(a) {
+			return false
+		}
+	}
+	return true
 }
 
 // Scan scan value into Jsonb, implements sql.Scanner interface
 // https://raaaaaaaay86.medium.com/how-to-store-plain-string-slice-by-using-gorm-f855602013e6
 // https://gorm.io/docs/data_types.html
+// Depending on the driver and the column type, the JSON comes as []byte or as
+// a string.
 func (a *Answers) Scan(value interface{}) error {
-	bytes, ok := value.([]byte)
-	if !ok {
+	var bytes []byte
+	switch v := value.(type) {
+	case nil:
+		*a = Answers{}
+		return nil
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
 		return errors.New(fmt.Sprint("Failed to unmarshal JSONB value:", value))
 	}
 
@@ -70,3 +428,32 @@ func (a Answers) Value() (driver.Value, error) {
 	}
 	return json.Marshal(Answers(a))
 }
+
+// Scan implements the sql.Scanner interface (see Answers.Scan)
+func (s *AnswerSet) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case nil:
+		*s = AnswerSet{}
+		return nil
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New(fmt.Sprint("Failed to unmarshal JSONB value:", value))
+	}
+
+	result := AnswerSet{}
+	err := json.Unmarshal(bytes, &result)
+	*s = result
+	return err
+}
+
+// Value implements the driver.Valuer interface (see Answers.Value)
+func (s AnswerSet) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	return json.Marshal([]int(s))
+}
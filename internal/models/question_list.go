@@ -18,6 +18,31 @@ func (ql QuestionList) Valid() QuestionList {
 	return result
 }
 
+// NotVoided returns only the questions that are not voided
+func (ql QuestionList) NotVoided() QuestionList {
+	result := QuestionList{}
+	for _, q := range ql {
+		if !q.Voided {
+			result = append(result, q)
+		}
+	}
+
+	return result
+}
+
+// SingleAnswer returns only the questions that are answered by picking
+// exactly one of the answers (e.g. with a single tap in a live Game)
+func (ql QuestionList) SingleAnswer() QuestionList {
+	result := QuestionList{}
+	for _, q := range ql {
+		if !q.IsFreeInput() && !q.IsMultipleChoice() {
+			result = append(result, q)
+		}
+	}
+
+	return result
+}
+
 func (ql QuestionList) InDifficultyRange(min, max int) QuestionList {
 	result := QuestionList{}
 	for _, q := range ql {
@@ -29,6 +54,21 @@ func (ql QuestionList) InDifficultyRange(min, max int) QuestionList {
 	return result
 }
 
+// InCategory returns only the questions in one of the given categories
+func (ql QuestionList) InCategory(categories ...string) QuestionList {
+	result := QuestionList{}
+	for _, q := range ql {
+		for _, c := range categories {
+			if q.Category == c {
+				result = append(result, q)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
 func (ql QuestionList) Suffled() QuestionList {
 	dest := make(QuestionList, len(ql))
 	perm := rand.Perm(len(ql))
@@ -106,20 +146,18 @@ func (ql QuestionList) Limit(limit int) QuestionList {
 
 func (ql QuestionList) Score() float64 {
 	totalQuestions := 0
-	correctAnswers := 0
+	correctAnswers := 0.0
 	for _, q := range ql {
-		if !q.Valid() {
-			continue // Invalid questions don't count in score
+		if !q.Valid() || q.Voided {
+			continue // Invalid and voided questions don't count in score
 		}
 		totalQuestions++
-		if q.UserAnswer != 0 && q.UserAnswer == q.RightAnswer {
-			correctAnswers++
-		}
+		correctAnswers += q.Credit()
 	}
 
 	if totalQuestions == 0 { // All questions invalid?
 		return 0.0
 	}
 
-	return (float64(correctAnswers) / float64(totalQuestions)) * 100
+	return (correctAnswers / float64(totalQuestions)) * 100
 }
@@ -99,6 +99,20 @@ questions:
 		})
 	})
 
+	Describe("#InCategory", func() {
+		It("returns only questions in the given categories", func() {
+			list := QuestionList{
+				{Text: "K1", Category: "kubernetes"},
+				{Text: "L1", Category: "linux"},
+				{Text: "G1", Category: "go"},
+				{Text: "K2", Category: "kubernetes"},
+			}
+
+			Expect(questionTextFromQuestionList(list.InCategory("kubernetes"))).To(HaveExactElements("K1", "K2"))
+			Expect(questionTextFromQuestionList(list.InCategory("linux", "go"))).To(HaveExactElements("L1", "G1"))
+		})
+	})
+
 	Describe("#Limit", func() {
 		var list QuestionList
 
@@ -190,6 +204,26 @@ questions:
 		It("returns the score percentage counting missing answers as wrong and ignoring invalid ones", func() {
 			Expect(list.Score()).To(Equal(50.0))
 		})
+
+		It("ignores voided questions", func() {
+			list[2].Voided = true // the wrongly answered one
+			Expect(list.Score()).To(BeNumerically("~", 66.67, 0.01))
+		})
+
+		It("counts partial credit of multiple-choice questions", func() {
+			list = append(list, Question{
+				Text:         "partially answered",
+				Type:         MultipleChoice,
+				Scoring:      PartialScoring,
+				RightAnswers: AnswerSet{1, 2},
+				UserAnswers:  AnswerSet{1},
+				Answers:      Answers{"ans 1", "ans 2", "ans 3"},
+			})
+			Expect(list.Score()).To(Equal(50.0))
+
+			list[len(list)-1].UserAnswers = AnswerSet{2, 1}
+			Expect(list.Score()).To(Equal(60.0))
+		})
 	})
 })
 
@@ -1,8 +1,14 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -10,13 +16,157 @@ import (
 type Prize struct {
 	Title       string `yaml:"title,omitempty"`
 	Description string `yaml:"description,omitempty"`
+	// The leaderboard rank(s) that win the prize. Either a single `rank: 1`
+	// or a range like `ranks: 2-3`.
+	Rank  int    `yaml:"rank,omitempty"`
+	Ranks string `yaml:"ranks,omitempty"`
 }
 
 type PrizeList []Prize
 
+// RankRange returns the first and last rank that win the prize. Both are 0
+// when the prize is not mapped to ranks.
+func (p Prize) RankRange() (int, int, error) {
+	if p.Ranks == "" {
+		return p.Rank, p.Rank, nil
+	}
+
+	first, last, isRange := strings.Cut(p.Ranks, "-")
+	if !isRange {
+		last = first
+	}
+	from, err := strconv.Atoi(strings.TrimSpace(first))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid ranks %q: %w", p.Ranks, err)
+	}
+	to, err := strconv.Atoi(strings.TrimSpace(last))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid ranks %q: %w", p.Ranks, err)
+	}
+	if from > to {
+		return 0, 0, fmt.Errorf("invalid ranks %q: %d is greater than %d", p.Ranks, from, to)
+	}
+
+	return from, to, nil
+}
+
+// RankLabel returns the ranks that win the prize for display, e.g. "#1" or "#2-3"
+func (p Prize) RankLabel() string {
+	from, to, err := p.RankRange()
+	if err != nil || from == 0 {
+		return ""
+	}
+	if from == to {
+		return fmt.Sprintf("#%d", from)
+	}
+	return fmt.Sprintf("#%d-%d", from, to)
+}
+
+// ForRank returns the prize won by the given rank (if any)
+func (pl PrizeList) ForRank(rank int) (Prize, bool) {
+	for _, p := range pl {
+		from, to, err := p.RankRange()
+		if err != nil || from == 0 {
+			continue
+		}
+		if rank >= from && rank <= to {
+			return p, true
+		}
+	}
+
+	return Prize{}, false
+}
+
 type QuestionPool struct {
+	// Category is assigned to all the questions that don't set their own
+	Category  string       `yaml:"category,omitempty"`
 	Questions QuestionList `yaml:"questions,omitempty"`
 	Prizes    PrizeList    `yaml:"prizes,omitempty"`
+	Quiz      QuizConfig   `yaml:"quiz,omitempty"`
+}
+
+// QuestionPoolFiles resolves the given path to a list of question pool files.
+// The path can be a file, a directory (all the .yaml and .yml files in it) or
+// a glob pattern.
+func QuestionPoolFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err == nil && !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	if err == nil { // a directory
+		for _, ext := range []string{"*.yaml", "*.yml"} {
+			matches, err := filepath.Glob(filepath.Join(path, ext))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+		}
+	} else {
+		if files, err = filepath.Glob(path); err != nil {
+			return nil, fmt.Errorf("invalid question pool pattern %s: %w", path, err)
+		}
+	}
+	sort.Strings(files)
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no question pool files found in %s", path)
+	}
+
+	return files, nil
+}
+
+// NewQuestionPoolFromPath loads and merges all the question pool files found
+// in the path (see QuestionPoolFiles). It returns an error if two questions
+// have the same id (see DerivedPoolID), even in different files.
+func NewQuestionPoolFromPath(path string) (QuestionPool, error) {
+	files, err := QuestionPoolFiles(path)
+	if err != nil {
+		return QuestionPool{}, err
+	}
+
+	result := QuestionPool{}
+	definedIn := map[string]string{} // pool id -> file
+	for _, f := range files {
+		pool, err := NewQuestionPoolFromFile(f)
+		if err != nil {
+			return QuestionPool{}, err
+		}
+		// the answers are linked to the questions by id, so it has to be
+		// unique across all the files
+		for _, q := range pool.Questions {
+			if other, found := definedIn[q.PoolID]; found {
+				return QuestionPool{}, fmt.Errorf("duplicate question id %q in %s (already defined in %s)", q.PoolID, f, other)
+			}
+			definedIn[q.PoolID] = f
+		}
+		result = result.Merge(pool)
+	}
+
+	return result, nil
+}
+
+// Merge returns a pool with the questions and prizes of both pools. The quiz
+// settings of `other` take precedence.
+func (qp QuestionPool) Merge(other QuestionPool) QuestionPool {
+	result := QuestionPool{
+		Questions: append(append(QuestionList{}, qp.Questions...), other.Questions...),
+		Prizes:    append(append(PrizeList{}, qp.Prizes...), other.Prizes...),
+		Quiz:      qp.Quiz.Merge(other.Quiz),
+	}
+
+	return result
+}
+
+// DerivedPoolID returns the identity of a question that doesn't set an `id`
+// in the pool. It's derived from the text only, so that fixing the answers
+// keeps the identity while changing the text creates a new question.
+func DerivedPoolID(text string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	sum := sha256.Sum256([]byte(normalized))
+
+	return "sha-" + hex.EncodeToString(sum[:])[:12]
 }
 
 func NewQuestionPoolFromFile(filePath string) (QuestionPool, error) {
@@ -35,5 +185,14 @@ func NewQuestionPool(template string) (QuestionPool, error) {
 		return result, fmt.Errorf("unmarshaling template: %w", err)
 	}
 
+	for i := range result.Questions {
+		if result.Questions[i].Category == "" {
+			result.Questions[i].Category = result.Category
+		}
+		if result.Questions[i].PoolID == "" {
+			result.Questions[i].PoolID = DerivedPoolID(result.Questions[i].Text)
+		}
+	}
+
 	return result, nil
 }
@@ -1,6 +1,10 @@
 package models_test
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+
 	. "github.com/jimmykarily/quizmaker/internal/models"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -20,4 +24,88 @@ var _ = Describe("QuestionPool", func() {
 			Expect(len(p.Questions)).To(Equal(20))
 		})
 	})
+
+	Describe("NewQuestionPool", func() {
+		It("derives an id from the text for questions without one", func() {
+			p, err := NewQuestionPool(`
+questions:
+  - id: my-question
+    text: Q1
+  - text: Q2
+  - text: "  q2 "
+`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.Questions[0].PoolID).To(Equal("my-question"))
+			Expect(p.Questions[1].PoolID).To(HavePrefix("sha-"))
+			Expect(p.Questions[1].PoolID).To(Equal(p.Questions[2].PoolID))
+		})
+	})
+
+	Describe("NewQuestionPoolFromPath", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(dir, "kubernetes.yaml"), []byte(`
+category: kubernetes
+questions:
+  - text: K1
+  - text: K2
+    category: networking
+prizes:
+  - title: First
+`), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "linux.yml"), []byte(`
+category: linux
+questions:
+  - text: L1
+`), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a pool"), 0644)).To(Succeed())
+		})
+
+		It("merges all the files in a directory tagging the questions with their category", func() {
+			p, err := NewQuestionPoolFromPath(dir)
+			Expect(err).ToNot(HaveOccurred())
+
+			categories := map[string]string{}
+			for _, q := range p.Questions {
+				categories[q.Text] = q.Category
+			}
+			Expect(categories).To(Equal(map[string]string{
+				"K1": "kubernetes",
+				"K2": "networking",
+				"L1": "linux",
+			}))
+			Expect(len(p.Prizes)).To(Equal(1))
+		})
+
+		It("accepts glob patterns", func() {
+			p, err := NewQuestionPoolFromPath(filepath.Join(dir, "*.yml"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(questionTextFromQuestionList(p.Questions)).To(HaveExactElements("L1"))
+		})
+
+		It("accepts a single file", func() {
+			p, err := NewQuestionPoolFromPath(poolPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(p.Questions)).To(Equal(20))
+		})
+
+		It("returns an error when two files have a question with the same id", func() {
+			Expect(os.WriteFile(filepath.Join(dir, "more.yaml"), []byte(`
+questions:
+  - text: "  k1"
+`), 0644)).To(Succeed())
+
+			_, err := NewQuestionPoolFromPath(dir)
+			Expect(err).To(MatchError(ContainSubstring(
+				fmt.Sprintf("duplicate question id %q in %s (already defined in %s)",
+					DerivedPoolID("K1"), filepath.Join(dir, "more.yaml"), filepath.Join(dir, "kubernetes.yaml")))))
+		})
+
+		It("returns an error when no files match", func() {
+			_, err := NewQuestionPoolFromPath(filepath.Join(dir, "*.json"))
+			Expect(err).To(MatchError(ContainSubstring("no question pool files found")))
+		})
+	})
 })
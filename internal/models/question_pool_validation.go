@@ -0,0 +1,310 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	MinAllowedDifficulty = 1
+	MaxAllowedDifficulty = 10
+)
+
+var knownQuestionTypes = []QuestionType{
+	"", // defaults to single-choice
+	SingleChoice,
+	MultipleChoice,
+	Boolean,
+	TextAnswer,
+	NumericAnswer,
+}
+
+// PoolProblem is an issue found in a question pool file
+type PoolProblem struct {
+	File     string // only set when validating a path
+	Line     int
+	Question string
+	Message  string
+}
+
+func (p PoolProblem) String() string {
+	location := fmt.Sprintf("line %d", p.Line)
+	if p.File != "" {
+		location = fmt.Sprintf("%s:%d", p.File, p.Line)
+	}
+	if p.Question == "" {
+		return fmt.Sprintf("%s: %s", location, p.Message)
+	}
+	return fmt.Sprintf("%s: %s (question: %q)", location, p.Message, p.Question)
+}
+
+// PoolValidationReport is the result of validating a question pool file
+type PoolValidationReport struct {
+	Problems []PoolProblem
+	// ValidByDifficulty is the number of valid questions per difficulty level
+	ValidByDifficulty map[int]int
+
+	ids map[string]PoolProblem // the location of every question id (see DerivedPoolID)
+}
+
+// Difficulties returns the difficulty levels in the histogram in ascending order
+func (r PoolValidationReport) Difficulties() []int {
+	result := []int{}
+	for d := range r.ValidByDifficulty {
+		result = append(result, d)
+	}
+	sort.Ints(result)
+
+	return result
+}
+
+func ValidateQuestionPoolFile(filePath string) (PoolValidationReport, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return PoolValidationReport{}, fmt.Errorf("reading file %s: %w", filePath, err)
+	}
+
+	return ValidateQuestionPool(string(b))
+}
+
+// ValidateQuestionPoolPath validates all the files in the path (see
+// QuestionPoolFiles) and combines the reports.
+func ValidateQuestionPoolPath(path string) (PoolValidationReport, error) {
+	report := PoolValidationReport{ValidByDifficulty: map[int]int{}}
+
+	files, err := QuestionPoolFiles(path)
+	if err != nil {
+		return report, err
+	}
+
+	definedIn := map[string]PoolProblem{}
+	for _, f := range files {
+		fileReport, err := ValidateQuestionPoolFile(f)
+		if err != nil {
+			return report, err
+		}
+		// duplicates within a file are already reported
+		for id, location := range fileReport.ids {
+			if first, found := definedIn[id]; found {
+				fileReport.Problems = append(fileReport.Problems, PoolProblem{
+					Line:     location.Line,
+					Question: location.Question,
+					Message:  fmt.Sprintf("duplicate id %q (first defined in %s:%d)", id, first.File, first.Line),
+				})
+			} else {
+				location.File = f
+				definedIn[id] = location
+			}
+		}
+		sort.SliceStable(fileReport.Problems, func(i, j int) bool {
+			return fileReport.Problems[i].Line < fileReport.Problems[j].Line
+		})
+		for _, p := range fileReport.Problems {
+			p.File = f
+			report.Problems = append(report.Problems, p)
+		}
+		for d, n := range fileReport.ValidByDifficulty {
+			report.ValidByDifficulty[d] += n
+		}
+	}
+
+	return report, nil
+}
+
+// ValidateQuestionPool reports every problem found in the questions of the
+// given template, along with the line it was found on. Unlike
+// QuestionList.Valid it doesn't silently drop the invalid questions.
+func ValidateQuestionPool(template string) (PoolValidationReport, error) {
+	report := PoolValidationReport{ValidByDifficulty: map[int]int{}, ids: map[string]PoolProblem{}}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(template), &doc); err != nil {
+		return report, fmt.Errorf("unmarshaling template: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return report, nil // empty file
+	}
+
+	if prizes := mappingValue(doc.Content[0], "prizes"); prizes != nil && prizes.Kind == yaml.SequenceNode {
+		for _, node := range prizes.Content {
+			var p Prize
+			if err := node.Decode(&p); err != nil {
+				report.Problems = append(report.Problems, PoolProblem{Line: node.Line, Message: err.Error()})
+				continue
+			}
+			if _, _, err := p.RankRange(); err != nil {
+				report.Problems = append(report.Problems, PoolProblem{Line: lineOf(node, "ranks"), Message: err.Error()})
+			}
+		}
+	}
+
+	questions := mappingValue(doc.Content[0], "questions")
+	if questions == nil {
+		report.Problems = append(report.Problems, PoolProblem{Line: doc.Content[0].Line, Message: "no questions defined"})
+		return report, nil
+	}
+	if questions.Kind != yaml.SequenceNode {
+		report.Problems = append(report.Problems, PoolProblem{Line: questions.Line, Message: "questions should be a list"})
+		return report, nil
+	}
+
+	seenTexts := map[string]int{}
+	seenIDs := map[string]int{}
+	for _, node := range questions.Content {
+		var q Question
+		if err := node.Decode(&q); err != nil {
+			report.Problems = append(report.Problems, PoolProblem{Line: node.Line, Message: err.Error()})
+			continue
+		}
+
+		problems := questionProblems(q, node)
+		if line, found := seenTexts[q.Text]; found && q.Text != "" {
+			problems = append(problems, PoolProblem{
+				Line:    lineOf(node, "text"),
+				Message: fmt.Sprintf("duplicate question text (first defined on line %d)", line),
+			})
+		} else {
+			seenTexts[q.Text] = lineOf(node, "text")
+		}
+
+		if q.PoolID != "" {
+			if line, found := seenIDs[q.PoolID]; found {
+				problems = append(problems, PoolProblem{
+					Line:    lineOf(node, "id"),
+					Message: fmt.Sprintf("duplicate id %q (first defined on line %d)", q.PoolID, line),
+				})
+			} else {
+				seenIDs[q.PoolID] = lineOf(node, "id")
+			}
+		}
+
+		id, idKey := q.PoolID, "id"
+		if id == "" {
+			id, idKey = DerivedPoolID(q.Text), "text"
+		}
+		if first, found := report.ids[id]; !found {
+			report.ids[id] = PoolProblem{Line: lineOf(node, idKey), Question: q.Text}
+		} else if q.PoolID == "" && first.Question != q.Text {
+			problems = append(problems, PoolProblem{
+				Line:    lineOf(node, "text"),
+				Message: fmt.Sprintf("same text as the question on line %d, ignoring case and whitespace (it would get the same id)", first.Line),
+			})
+		}
+
+		for i := range problems {
+			problems[i].Question = q.Text
+		}
+		report.Problems = append(report.Problems, problems...)
+
+		if len(problems) == 0 && q.Valid() {
+			report.ValidByDifficulty[q.Difficulty]++
+		}
+	}
+
+	sort.SliceStable(report.Problems, func(i, j int) bool {
+		return report.Problems[i].Line < report.Problems[j].Line
+	})
+
+	return report, nil
+}
+
+func questionProblems(q Question, node *yaml.Node) []PoolProblem {
+	result := []PoolProblem{}
+	add := func(key, format string, args ...interface{}) {
+		result = append(result, PoolProblem{Line: lineOf(node, key), Message: fmt.Sprintf(format, args...)})
+	}
+
+	if q.Text == "" {
+		add("text", "missing text")
+	}
+
+	if q.Difficulty < MinAllowedDifficulty || q.Difficulty > MaxAllowedDifficulty {
+		add("difficulty", "difficulty %d is outside %d-%d", q.Difficulty, MinAllowedDifficulty, MaxAllowedDifficulty)
+	}
+
+	knownType := false
+	for _, t := range knownQuestionTypes {
+		if q.Type == t {
+			knownType = true
+		}
+	}
+	if !knownType {
+		add("type", "unknown type %q", q.Type)
+		return result
+	}
+
+	switch q.Type {
+	case TextAnswer:
+		if len(q.AcceptedAnswers) == 0 && q.AnswerPattern == "" {
+			add("acceptedAnswers", "missing acceptedAnswers or answerPattern")
+		}
+		if q.AnswerPattern != "" {
+			if _, err := regexp.Compile(q.AnswerPattern); err != nil {
+				add("answerPattern", "invalid answerPattern: %s", err.Error())
+			}
+		}
+		return result
+	case NumericAnswer:
+		if len(q.AcceptedAnswers) == 0 {
+			add("acceptedAnswers", "missing acceptedAnswers")
+		}
+		for _, a := range q.AcceptedAnswers {
+			if _, err := strconv.ParseFloat(a, 64); err != nil {
+				add("acceptedAnswers", "accepted answer %q is not a number", a)
+			}
+		}
+		if q.Tolerance < 0 {
+			add("tolerance", "tolerance can't be negative")
+		}
+		return result
+	}
+
+	if len(q.Answers) == 0 {
+		add("answers", "empty answers")
+	}
+
+	if q.IsMultipleChoice() && len(q.RightAnswers) > 0 {
+		for _, a := range q.RightAnswers {
+			if a < 1 || a > len(q.Answers) {
+				add("rightAnswers", "rightAnswers index %d is out of range (1-%d)", a, len(q.Answers))
+			}
+		}
+		return result
+	}
+
+	if q.RightAnswer == 0 {
+		add("rightAnswer", "missing rightAnswer")
+	} else if q.RightAnswer < 1 || q.RightAnswer > len(q.Answers) {
+		add("rightAnswer", "rightAnswer index %d is out of range (1-%d)", q.RightAnswer, len(q.Answers))
+	}
+
+	return result
+}
+
+// mappingValue returns the value node of the given key or nil if the node is
+// not a mapping or the key doesn't exist.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// lineOf returns the line of the given key's value or the line of the node
+// itself when the key is not there.
+func lineOf(node *yaml.Node, key string) int {
+	if v := mappingValue(node, key); v != nil {
+		return v.Line
+	}
+	return node.Line
+}
@@ -0,0 +1,111 @@
+package models_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("QuestionPool validation", func() {
+	Describe("ValidateQuestionPoolFile", func() {
+		It("reports no problems for a valid pool", func() {
+			report, err := ValidateQuestionPoolFile("../../tests/assets/question_pool.yaml")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(report.Problems).To(BeEmpty())
+			Expect(report.Difficulties()).To(HaveExactElements(1, 2, 3, 4, 5, 6, 7, 8, 9, 10))
+			Expect(report.ValidByDifficulty[1]).To(Equal(2))
+		})
+	})
+
+	Describe("ValidateQuestionPoolPath", func() {
+		It("reports the ids used in more than one file", func() {
+			dir := GinkgoT().TempDir()
+			question := func(id, text string) string {
+				if id != "" {
+					id = "id: " + id + "\n    "
+				}
+				return fmt.Sprintf("  - %stext: %s\n    difficulty: 1\n    rightAnswer: 1\n    answers: [a]\n", id, text)
+			}
+			Expect(os.WriteFile(filepath.Join(dir, "a.yaml"), []byte("questions:\n"+
+				question("q", "Q1")+question("", "Q2")), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "b.yaml"), []byte("questions:\n"+
+				question("", "Q3")+question("q", "Q4")+question("", "q2")+question("", "q3")), 0644)).To(Succeed())
+
+			report, err := ValidateQuestionPoolPath(dir)
+			Expect(err).ToNot(HaveOccurred())
+			problems := []string{}
+			for _, p := range report.Problems {
+				problems = append(problems, p.String())
+			}
+			a, b := filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")
+			Expect(problems).To(HaveExactElements(
+				fmt.Sprintf(`%s:6: duplicate id "q" (first defined in %s:2) (question: "Q4")`, b, a),
+				fmt.Sprintf(`%s:11: duplicate id %q (first defined in %s:7) (question: "q2")`, b, DerivedPoolID("Q2"), a),
+				fmt.Sprintf(`%s:15: same text as the question on line 2, ignoring case and whitespace (it would get the same id) (question: "q3")`, b),
+			))
+		})
+	})
+
+	Describe("ValidateQuestionPool", func() {
+		It("reports every problem with its line number", func() {
+			report, err := ValidateQuestionPool(`questions:
+  - text: Out of range
+    difficulty: 11
+    rightAnswer: 5
+    answers: [a, b]
+  - text: Out of range
+    difficulty: 2
+    type: weird
+  - text: No answers
+    difficulty: 2
+    answers: []
+  - text: Valid
+    difficulty: 3
+    rightAnswer: 1
+    answers: [a, b]
+`)
+			Expect(err).ToNot(HaveOccurred())
+
+			problems := []string{}
+			for _, p := range report.Problems {
+				problems = append(problems, p.String())
+			}
+			Expect(problems).To(HaveExactElements(
+				`line 3: difficulty 11 is outside 1-10 (question: "Out of range")`,
+				`line 4: rightAnswer index 5 is out of range (1-2) (question: "Out of range")`,
+				`line 6: duplicate question text (first defined on line 2) (question: "Out of range")`,
+				`line 8: unknown type "weird" (question: "Out of range")`,
+				`line 9: missing rightAnswer (question: "No answers")`,
+				`line 11: empty answers (question: "No answers")`,
+			))
+			Expect(report.ValidByDifficulty).To(Equal(map[int]int{3: 1}))
+		})
+
+		It("reports duplicate ids", func() {
+			report, err := ValidateQuestionPool(`questions:
+  - id: q
+    text: Q1
+    difficulty: 1
+    rightAnswer: 1
+    answers: [a]
+  - id: q
+    text: Q2
+    difficulty: 1
+    rightAnswer: 1
+    answers: [a]
+`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(report.Problems)).To(Equal(1))
+			Expect(report.Problems[0].String()).To(Equal(`line 7: duplicate id "q" (first defined on line 2) (question: "Q2")`))
+		})
+
+		It("returns an error when the yaml can't be parsed", func() {
+			_, err := ValidateQuestionPool("questions: [")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QuestionPoolWatcher keeps a parsed QuestionPool in memory and reloads it
+// when the files change. A new version of the files replaces the current pool
+// only if it parses and passes validation, otherwise the last good pool is kept.
+type QuestionPoolWatcher struct {
+	path     string
+	validate func(QuestionPool) error
+
+	mu          sync.RWMutex
+	pool        QuestionPool
+	fingerprint string
+}
+
+// NewQuestionPoolWatcher loads the pool from the given path (see
+// QuestionPoolFiles). The optional `validate` function is called on every
+// loaded pool and a non nil error rejects it.
+func NewQuestionPoolWatcher(path string, validate func(QuestionPool) error) (*QuestionPoolWatcher, error) {
+	w := &QuestionPoolWatcher{path: path, validate: validate}
+	if _, err := w.Reload(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Pool returns the last successfully loaded pool
+func (w *QuestionPoolWatcher) Pool() QuestionPool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.pool
+}
+
+// Reload re-reads the files if they changed since the last attempt. It returns
+// true if the pool was replaced.
+func (w *QuestionPoolWatcher) Reload() (bool, error) {
+	fingerprint, err := w.currentFingerprint()
+	if err != nil {
+		return false, fmt.Errorf("checking question pool files: %w", err)
+	}
+
+	w.mu.RLock()
+	unchanged := fingerprint == w.fingerprint
+	w.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	// Remember this version even if it's broken, so that we don't retry (and
+	// log) on every check until the files change again.
+	w.mu.Lock()
+	w.fingerprint = fingerprint
+	w.mu.Unlock()
+
+	pool, err := NewQuestionPoolFromPath(w.path)
+	if err != nil {
+		return false, err
+	}
+	if w.validate != nil {
+		if err := w.validate(pool); err != nil {
+			return false, fmt.Errorf("validating question pool: %w", err)
+		}
+	}
+
+	w.mu.Lock()
+	w.pool = pool
+	w.mu.Unlock()
+
+	return true, nil
+}
+
+// Watch polls the files for changes every `interval` until the context is done.
+func (w *QuestionPoolWatcher) Watch(ctx context.Context, interval time.Duration, infoLogger, errorLogger *log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := w.Reload()
+			if err != nil {
+				errorLogger.Printf("reloading question pool %s (keeping the previous one): %s\n", w.path, err.Error())
+				continue
+			}
+			if reloaded {
+				infoLogger.Printf("reloaded question pool %s\n", w.path)
+			}
+		}
+	}
+}
+
+// currentFingerprint describes the names, sizes and modification times of the
+// files in the path. It changes when any file is added, removed or modified.
+func (w *QuestionPoolWatcher) currentFingerprint() (string, error) {
+	files, err := QuestionPoolFiles(w.path)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "%s:%d:%d;", f, info.Size(), info.ModTime().UnixNano())
+	}
+
+	return sb.String(), nil
+}
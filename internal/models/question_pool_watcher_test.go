@@ -0,0 +1,66 @@
+package models_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("QuestionPoolWatcher", func() {
+	var poolPath string
+	var watcher *QuestionPoolWatcher
+
+	BeforeEach(func() {
+		poolPath = filepath.Join(GinkgoT().TempDir(), "questions.yaml")
+		Expect(os.WriteFile(poolPath, []byte("questions:\n  - text: Q1\n"), 0644)).To(Succeed())
+
+		var err error
+		watcher, err = NewQuestionPoolWatcher(poolPath, func(pool QuestionPool) error {
+			if len(pool.Questions) == 0 {
+				return errors.New("no questions")
+			}
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("loads the pool", func() {
+		Expect(questionTextFromQuestionList(watcher.Pool().Questions)).To(HaveExactElements("Q1"))
+	})
+
+	It("doesn't reload when the file didn't change", func() {
+		reloaded, err := watcher.Reload()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reloaded).To(BeFalse())
+	})
+
+	It("replaces the pool when the file changes", func() {
+		Expect(os.WriteFile(poolPath, []byte("questions:\n  - text: Q1\n  - text: Q2\n"), 0644)).To(Succeed())
+
+		reloaded, err := watcher.Reload()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reloaded).To(BeTrue())
+		Expect(questionTextFromQuestionList(watcher.Pool().Questions)).To(HaveExactElements("Q1", "Q2"))
+	})
+
+	It("keeps the last good pool when the new file can't be parsed", func() {
+		Expect(os.WriteFile(poolPath, []byte("questions:\n  - text: [Q1"), 0644)).To(Succeed())
+
+		reloaded, err := watcher.Reload()
+		Expect(err).To(HaveOccurred())
+		Expect(reloaded).To(BeFalse())
+		Expect(questionTextFromQuestionList(watcher.Pool().Questions)).To(HaveExactElements("Q1"))
+	})
+
+	It("keeps the last good pool when the new file is not valid", func() {
+		Expect(os.WriteFile(poolPath, []byte("questions: []\n"), 0644)).To(Succeed())
+
+		_, err := watcher.Reload()
+		Expect(err).To(MatchError(ContainSubstring("no questions")))
+		Expect(questionTextFromQuestionList(watcher.Pool().Questions)).To(HaveExactElements("Q1"))
+	})
+})
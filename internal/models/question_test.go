@@ -1,6 +1,7 @@
 package models_test
 
 import (
+	"strings"
 	"time"
 
 	. "github.com/jimmykarily/quizmaker/internal/models"
@@ -45,6 +46,47 @@ var _ = Describe("Question", func() {
 			}
 			Expect(question.Expired()).To(BeFalse())
 		})
+
+		It("returns true when the expiry was recorded, even if not started", func() {
+			question := Question{
+				Text:           "abandoned question",
+				AllowedSeconds: 5,
+				ExpiredAt:      time.Now(),
+			}
+			Expect(question.Expired()).To(BeTrue())
+			Expect(question.Status()).To(Equal(QuestionExpired))
+		})
+	})
+
+	Describe("#CheckAnswerAllowed", func() {
+		var question Question
+
+		BeforeEach(func() {
+			question = Question{
+				Text:           "started question",
+				StartedAt:      time.Now().Add(-10 * time.Second),
+				AllowedSeconds: 5,
+			}
+		})
+
+		It("rejects answers after the deadline", func() {
+			Expect(question.CheckAnswerAllowed(time.Now(), 0)).To(MatchError(ErrQuestionExpired))
+		})
+
+		It("accepts answers within the grace period", func() {
+			Expect(question.CheckAnswerAllowed(time.Now(), 10*time.Second)).To(Succeed())
+		})
+
+		It("rejects answers to questions that were not started", func() {
+			question.StartedAt = time.Time{}
+			Expect(question.CheckAnswerAllowed(time.Now(), time.Hour)).To(MatchError(ErrQuestionNotStarted))
+		})
+
+		It("rejects a second answer", func() {
+			question.AllowedSeconds = 60
+			question.UserAnswer = 1
+			Expect(question.CheckAnswerAllowed(time.Now(), 0)).To(MatchError(ErrQuestionAnswered))
+		})
 	})
 
 	Describe("#Valid", func() {
@@ -61,5 +103,144 @@ var _ = Describe("Question", func() {
 			question.RightAnswer = 1
 			Expect(question.Valid()).To(BeTrue())
 		})
+
+		It("checks all the right answers of multiple-choice questions", func() {
+			question := Question{
+				Type:         MultipleChoice,
+				RightAnswers: AnswerSet{1, 3},
+				Answers:      Answers{"answer1", "answer2"},
+			}
+			Expect(question.Valid()).To(BeFalse())
+			question.Answers = append(question.Answers, "answer3")
+			Expect(question.Valid()).To(BeTrue())
+		})
+	})
+
+	Describe("#Valid (free input)", func() {
+		It("requires accepted answers or a pattern for text questions", func() {
+			question := Question{Type: TextAnswer}
+			Expect(question.Valid()).To(BeFalse())
+			question.AnswerPattern = "^etcd$"
+			Expect(question.Valid()).To(BeTrue())
+			question.AnswerPattern = "[invalid"
+			Expect(question.Valid()).To(BeFalse())
+		})
+
+		It("requires numbers as accepted answers of numeric questions", func() {
+			question := Question{Type: NumericAnswer, AcceptedAnswers: Answers{"two"}}
+			Expect(question.Valid()).To(BeFalse())
+			question.AcceptedAnswers = Answers{"2.5"}
+			Expect(question.Valid()).To(BeTrue())
+		})
+	})
+
+	Describe("#Credit", func() {
+		var question Question
+
+		BeforeEach(func() {
+			question = Question{
+				Type:         MultipleChoice,
+				RightAnswers: AnswerSet{1, 3},
+				Answers:      Answers{"answer1", "answer2", "answer3", "answer4"},
+			}
+		})
+
+		It("gives full credit only for the exact set by default", func() {
+			question.UserAnswers = AnswerSet{3, 1}
+			Expect(question.Credit()).To(Equal(1.0))
+
+			question.UserAnswers = AnswerSet{1}
+			Expect(question.Credit()).To(Equal(0.0))
+
+			question.UserAnswers = AnswerSet{1, 2, 3}
+			Expect(question.Credit()).To(Equal(0.0))
+		})
+
+		It("gives partial credit when configured", func() {
+			question.Scoring = PartialScoring
+
+			question.UserAnswers = AnswerSet{1}
+			Expect(question.Credit()).To(Equal(0.5))
+
+			question.UserAnswers = AnswerSet{1, 2, 3}
+			Expect(question.Credit()).To(Equal(0.5))
+
+			question.UserAnswers = AnswerSet{2, 4}
+			Expect(question.Credit()).To(Equal(0.0))
+		})
+
+		It("works with single choice questions", func() {
+			question = Question{
+				RightAnswer: 2,
+				UserAnswer:  2,
+				Answers:     Answers{"answer1", "answer2"},
+			}
+			Expect(question.Credit()).To(Equal(1.0))
+			question.UserAnswer = 1
+			Expect(question.Credit()).To(Equal(0.0))
+		})
+
+		It("matches text answers ignoring case and extra whitespace", func() {
+			question := Question{
+				Type:            TextAnswer,
+				AcceptedAnswers: Answers{"Control Plane"},
+				UserTextAnswer:  "  control   plane ",
+			}
+			Expect(question.Credit()).To(Equal(1.0))
+
+			question.CaseSensitive = true
+			Expect(question.Credit()).To(Equal(0.0))
+		})
+
+		It("matches text answers against the pattern", func() {
+			question := Question{
+				Type:           TextAnswer,
+				AnswerPattern:  "^kube-?proxy$",
+				UserTextAnswer: "KubeProxy",
+			}
+			Expect(question.Credit()).To(Equal(1.0))
+
+			question.UserTextAnswer = "kubelet"
+			Expect(question.Credit()).To(Equal(0.0))
+		})
+
+		It("matches numeric answers within the tolerance", func() {
+			question := Question{
+				Type:            NumericAnswer,
+				AcceptedAnswers: Answers{"2379"},
+				Tolerance:       1,
+				UserTextAnswer:  "2380",
+			}
+			Expect(question.Credit()).To(Equal(1.0))
+
+			question.UserTextAnswer = "2381"
+			Expect(question.Credit()).To(Equal(0.0))
+
+			question.UserTextAnswer = "not a number"
+			Expect(question.Credit()).To(Equal(0.0))
+		})
+	})
+	Describe("Answers#Scan", func() {
+		It("accepts the JSON as bytes or as a string, and NULL", func() {
+			var answers Answers
+			Expect(answers.Scan([]byte(`["a","b"]`))).To(Succeed())
+			Expect(answers).To(Equal(Answers{"a", "b"}))
+			Expect(answers.Scan(`["c"]`)).To(Succeed())
+			Expect(answers).To(Equal(Answers{"c"}))
+			Expect(answers.Scan(nil)).To(Succeed())
+			Expect(answers).To(BeEmpty())
+			Expect(answers.Scan(42)).ToNot(Succeed())
+		})
+	})
+
+	It("stores answers longer than 255 characters", func() {
+		long := strings.Repeat("a very long answer ", 20)
+		session := Session{Email: "alice@example.com", Questions: []Question{{Text: "question", Answers: Answers{long, long}}}}
+		Expect(db.Create(&session).Error).ToNot(HaveOccurred())
+
+		var question Question
+		Expect(db.First(&question, session.Questions[0].ID).Error).ToNot(HaveOccurred())
+		Expect(question.Answers).To(Equal(Answers{long, long}))
+		Expect(question.StartedAt.IsZero()).To(BeTrue())
 	})
 })
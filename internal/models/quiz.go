@@ -3,42 +3,229 @@ package models
 import (
 	"errors"
 	"fmt"
+	"sort"
 
 	"gorm.io/gorm"
 )
 
+const (
+	DefaultTotalQuestions     = 15
+	DefaultMinDifficulty      = 1
+	DefaultMaxDifficulty      = 10
+	DefaultQuestionTimeoutSec = 30
+)
+
+// QuizConfig holds the quiz parameters that can be set per event in the
+// `quiz` section of the question pool file. Zero values mean "not set".
+type QuizConfig struct {
+	TotalQuestions     int `yaml:"totalQuestions,omitempty"`
+	MinDifficulty      int `yaml:"minDifficulty,omitempty"`
+	MaxDifficulty      int `yaml:"maxDifficulty,omitempty"`
+	QuestionTimeoutSec int `yaml:"questionTimeoutSec,omitempty"`
+	// ExtraSecondsPerDifficulty is added to QuestionTimeoutSec for every
+	// difficulty level above 1. It only applies to questions that don't
+	// define their own `allowedSeconds`.
+	ExtraSecondsPerDifficulty int `yaml:"extraSecondsPerDifficulty,omitempty"`
+	// Categories picks the given number of questions from each category
+	// (e.g. 5 from "kubernetes" and 5 from "linux"). When set, the total
+	// number of questions is the sum of the numbers.
+	Categories CategoryMix   `yaml:"categories,omitempty"`
+	Scoring    ScoringConfig `yaml:"scoring,omitempty"`
+}
+
+// CategoryMix maps a question category to the number of questions to pick from it
+type CategoryMix map[string]int
+
 type QuizOptions struct {
-	TotalQuestions     int
-	MinDifficulty      int
-	MaxDifficulty      int
-	QuestionTimeoutSec int
-	AvailableQuestions QuestionList
+	TotalQuestions            int
+	MinDifficulty             int
+	MaxDifficulty             int
+	QuestionTimeoutSec        int
+	ExtraSecondsPerDifficulty int
+	Categories                CategoryMix
+	Scoring                   ScoringConfig
+	AvailableQuestions        QuestionList
+}
+
+// Total returns the number of questions in the mix
+func (m CategoryMix) Total() int {
+	total := 0
+	for _, n := range m {
+		total += n
+	}
+	return total
+}
+
+// Names returns the categories in the mix in alphabetical order
+func (m CategoryMix) Names() []string {
+	result := []string{}
+	for c := range m {
+		result = append(result, c)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+func DefaultQuizConfig() QuizConfig {
+	return QuizConfig{
+		TotalQuestions:     DefaultTotalQuestions,
+		MinDifficulty:      DefaultMinDifficulty,
+		MaxDifficulty:      DefaultMaxDifficulty,
+		QuestionTimeoutSec: DefaultQuestionTimeoutSec,
+	}
+}
+
+// Merge returns a copy of the config where every field that is set in
+// `override` replaces the current value.
+func (c QuizConfig) Merge(override QuizConfig) QuizConfig {
+	if override.TotalQuestions != 0 {
+		c.TotalQuestions = override.TotalQuestions
+	}
+	if override.MinDifficulty != 0 {
+		c.MinDifficulty = override.MinDifficulty
+	}
+	if override.MaxDifficulty != 0 {
+		c.MaxDifficulty = override.MaxDifficulty
+	}
+	if override.QuestionTimeoutSec != 0 {
+		c.QuestionTimeoutSec = override.QuestionTimeoutSec
+	}
+	if override.ExtraSecondsPerDifficulty != 0 {
+		c.ExtraSecondsPerDifficulty = override.ExtraSecondsPerDifficulty
+	}
+	if len(override.Categories) > 0 {
+		c.Categories = override.Categories
+	}
+	c.Scoring = c.Scoring.Merge(override.Scoring)
+
+	return c
+}
+
+// QuizOptionsFor returns the options to build a quiz from the given pool.
+// The defaults are overridden by the `quiz` section of the pool which in turn
+// is overridden by `overrides` (e.g. command line flags).
+func QuizOptionsFor(pool QuestionPool, overrides QuizConfig) QuizOptions {
+	c := DefaultQuizConfig().Merge(pool.Quiz).Merge(overrides)
+	if len(c.Categories) > 0 {
+		c.TotalQuestions = c.Categories.Total()
+	}
+
+	return QuizOptions{
+		TotalQuestions:            c.TotalQuestions,
+		MinDifficulty:             c.MinDifficulty,
+		MaxDifficulty:             c.MaxDifficulty,
+		QuestionTimeoutSec:        c.QuestionTimeoutSec,
+		ExtraSecondsPerDifficulty: c.ExtraSecondsPerDifficulty,
+		Categories:                c.Categories,
+		Scoring:                   c.Scoring,
+		AvailableQuestions:        pool.Questions,
+	}
+}
+
+// Validate checks that the options make sense and that a quiz can actually
+// be built out of the available questions.
+func (opts QuizOptions) Validate() error {
+	if opts.TotalQuestions < 1 {
+		return errors.New("total questions should be at least 1")
+	}
+	if opts.QuestionTimeoutSec < 1 {
+		return errors.New("question timeout should be at least 1 second")
+	}
+	if opts.ExtraSecondsPerDifficulty < 0 {
+		return errors.New("extra seconds per difficulty can't be negative")
+	}
+	if err := opts.Scoring.Validate(); err != nil {
+		return err
+	}
+	for _, d := range []int{opts.MinDifficulty, opts.MaxDifficulty} {
+		if d < MinAllowedDifficulty || d > MaxAllowedDifficulty {
+			return fmt.Errorf("difficulty %d is outside %d-%d", d, MinAllowedDifficulty, MaxAllowedDifficulty)
+		}
+	}
+	if opts.MinDifficulty > opts.MaxDifficulty {
+		return fmt.Errorf("min difficulty (%d) is greater than max difficulty (%d)",
+			opts.MinDifficulty, opts.MaxDifficulty)
+	}
+
+	candidates := opts.AvailableQuestions.Valid().NotVoided().InDifficultyRange(opts.MinDifficulty, opts.MaxDifficulty)
+	for _, c := range opts.Categories.Names() {
+		requested := opts.Categories[c]
+		if requested < 0 {
+			return fmt.Errorf("negative number of questions for category %q", c)
+		}
+		available := len(candidates.InCategory(c))
+		if requested > available {
+			return fmt.Errorf("not enough questions in category %q: %d requested but only %d valid questions with difficulty %d-%d",
+				c, requested, available, opts.MinDifficulty, opts.MaxDifficulty)
+		}
+	}
+
+	available := len(candidates)
+	if opts.TotalQuestions > available {
+		return fmt.Errorf("not enough questions: %d requested but only %d valid questions with difficulty %d-%d",
+			opts.TotalQuestions, available, opts.MinDifficulty, opts.MaxDifficulty)
+	}
+
+	return nil
 }
 
 // Quiz is the collection of questions from a QuestionPool based on QuizOptions.
 // It's an intermidiate model used to prepare the Questions that will be stored
 // in the database.
 type Quiz struct {
-	Questions QuestionList `yaml:"questions,omitempty"`
+	Questions QuestionList  `yaml:"questions,omitempty"`
+	Scoring   ScoringConfig `yaml:"scoring,omitempty"`
 }
 
 func NewQuizWithOpts(opts QuizOptions) (Quiz, error) {
-	result := Quiz{}
+	result := Quiz{Scoring: opts.Scoring}
 
-	result.Questions = opts.AvailableQuestions.Valid().InDifficultyRange(opts.MinDifficulty, opts.MaxDifficulty)
+	result.Questions = opts.AvailableQuestions.Valid().NotVoided().InDifficultyRange(opts.MinDifficulty, opts.MaxDifficulty)
 
 	if opts.TotalQuestions > len(result.Questions) {
 		return result, errors.New("not enough questions")
 	}
 
-	result.Questions = result.Questions.Limit(opts.TotalQuestions).OrderedByDifficulty()
+	if len(opts.Categories) == 0 {
+		result.Questions = result.Questions.Limit(opts.TotalQuestions).OrderedByDifficulty()
+	} else {
+		selected := QuestionList{}
+		for _, c := range opts.Categories.Names() {
+			inCategory := result.Questions.InCategory(c)
+			if opts.Categories[c] > len(inCategory) {
+				return result, fmt.Errorf("not enough questions in category %q", c)
+			}
+			selected = append(selected, inCategory.Limit(opts.Categories[c])...)
+		}
+		result.Questions = selected.OrderedByDifficulty()
+	}
 	for i := range result.Questions {
-		result.Questions[i].AllowedSeconds = opts.QuestionTimeoutSec
+		// questions with their own time limit in the pool keep it
+		if result.Questions[i].AllowedSeconds > 0 {
+			continue
+		}
+		result.Questions[i].AllowedSeconds = opts.AllowedSecondsFor(result.Questions[i])
 	}
 
 	return result, nil
 }
 
+// AllowedSecondsFor returns the default time limit for the given question,
+// scaled by its difficulty when ExtraSecondsPerDifficulty is set.
+func (opts QuizOptions) AllowedSecondsFor(q Question) int {
+	extraLevels := q.Difficulty - 1
+	if extraLevels < 0 {
+		extraLevels = 0
+	}
+
+	return opts.QuestionTimeoutSec + extraLevels*opts.ExtraSecondsPerDifficulty
+}
+
+// PersistForSessionEmail saves the questions of the quiz as the questions of
+// the session with the given email. It does nothing if the session already
+// has questions, e.g. because a concurrent request started the same quiz: the
+// session stays locked until the questions are saved.
 func (quiz Quiz) PersistForSessionEmail(db *gorm.DB, email string) error {
 	s, err := SessionForEmail(db, email)
 	if err != nil {
@@ -48,5 +235,27 @@ func (quiz Quiz) PersistForSessionEmail(db *gorm.DB, email string) error {
 		quiz.Questions[i].Index = i + 1
 	}
 
-	return db.Model(&s).Association("Questions").Append(quiz.Questions)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := lockSession(tx, s.ID); err != nil {
+			return err
+		}
+		var existing int64
+		if err := tx.Model(&Question{}).Where("session_id = ?", s.ID).Count(&existing).Error; err != nil {
+			return fmt.Errorf("counting the questions of %s: %w", email, err)
+		}
+		if existing > 0 {
+			return nil
+		}
+
+		// reloaded so that Save doesn't undo what changed since the look up
+		if err := tx.First(&s, s.ID).Error; err != nil {
+			return fmt.Errorf("looking up session for email %s: %w", email, err)
+		}
+		s.Scoring = quiz.Scoring
+		if err := tx.Save(&s).Error; err != nil {
+			return fmt.Errorf("saving scoring configuration for email %s: %w", email, err)
+		}
+
+		return tx.Model(&s).Association("Questions").Append(quiz.Questions)
+	})
 }
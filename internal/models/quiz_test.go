@@ -34,6 +34,101 @@ var _ = Describe("Quiz", func() {
 			Expect(len(q.Questions)).To(Equal(4))
 		})
 
+		Describe("time limits", func() {
+			BeforeEach(func() {
+				pool, err := NewQuestionPool(`
+questions:
+  - text: with own time limit
+    difficulty: 3
+    allowedSeconds: 60
+    rightAnswer: 1
+    answers: [a, b]
+  - text: without time limit
+    difficulty: 3
+    rightAnswer: 1
+    answers: [a, b]
+`)
+				Expect(err).ToNot(HaveOccurred())
+				opts.AvailableQuestions = pool.Questions
+				opts.TotalQuestions = 2
+			})
+
+			It("keeps the time limit from the pool and uses the default otherwise", func() {
+				q, err := NewQuizWithOpts(opts)
+				Expect(err).ToNot(HaveOccurred())
+
+				allowed := map[string]int{}
+				for _, question := range q.Questions {
+					allowed[question.Text] = question.AllowedSeconds
+				}
+				Expect(allowed).To(Equal(map[string]int{
+					"with own time limit": 60,
+					"without time limit":  10,
+				}))
+			})
+
+			It("scales the default by difficulty when configured", func() {
+				opts.ExtraSecondsPerDifficulty = 5
+				q, err := NewQuizWithOpts(opts)
+				Expect(err).ToNot(HaveOccurred())
+
+				allowed := map[string]int{}
+				for _, question := range q.Questions {
+					allowed[question.Text] = question.AllowedSeconds
+				}
+				Expect(allowed).To(Equal(map[string]int{
+					"with own time limit": 60,
+					"without time limit":  20,
+				}))
+			})
+		})
+
+		Describe("categories", func() {
+			BeforeEach(func() {
+				pool, err := NewQuestionPool(`
+questions:
+  - {text: K1, category: kubernetes, difficulty: 2, rightAnswer: 1, answers: [a]}
+  - {text: K2, category: kubernetes, difficulty: 3, rightAnswer: 1, answers: [a]}
+  - {text: K3, category: kubernetes, difficulty: 4, rightAnswer: 1, answers: [a]}
+  - {text: L1, category: linux, difficulty: 2, rightAnswer: 1, answers: [a]}
+  - {text: L2, category: linux, difficulty: 3, rightAnswer: 1, answers: [a]}
+  - {text: G1, category: go, difficulty: 2, rightAnswer: 1, answers: [a]}
+`)
+				Expect(err).ToNot(HaveOccurred())
+				opts.AvailableQuestions = pool.Questions
+				opts.Categories = CategoryMix{"kubernetes": 2, "linux": 1}
+				opts.TotalQuestions = opts.Categories.Total()
+			})
+
+			It("picks the requested number of questions from each category", func() {
+				q, err := NewQuizWithOpts(opts)
+				Expect(err).ToNot(HaveOccurred())
+
+				counts := map[string]int{}
+				for _, question := range q.Questions {
+					counts[question.Category]++
+				}
+				Expect(counts).To(Equal(map[string]int{"kubernetes": 2, "linux": 1}))
+			})
+
+			It("returns an error when a category doesn't have enough questions", func() {
+				opts.Categories["go"] = 2
+				opts.TotalQuestions = opts.Categories.Total()
+				Expect(opts.Validate()).To(MatchError(ContainSubstring(`not enough questions in category "go"`)))
+			})
+		})
+
+		It("doesn't pick voided questions", func() {
+			for i := range opts.AvailableQuestions {
+				opts.AvailableQuestions[i].Voided = opts.AvailableQuestions[i].Difficulty == 2
+			}
+			q, err := NewQuizWithOpts(opts)
+			Expect(err).ToNot(HaveOccurred())
+			for _, question := range q.Questions {
+				Expect(question.Difficulty).ToNot(Equal(2))
+			}
+		})
+
 		Describe("validations", func() {
 			When("there are not enough questions in the pool", func() {
 				BeforeEach(func() {
@@ -48,6 +143,65 @@ var _ = Describe("Quiz", func() {
 		})
 	})
 
+	Describe("#QuizOptionsFor", func() {
+		var pool QuestionPool
+
+		BeforeEach(func() {
+			var err error
+			pool, err = NewQuestionPool(`
+quiz:
+  totalQuestions: 5
+  questionTimeoutSec: 20
+questions:
+  - text: Q1D1
+    difficulty: 1
+`)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("uses the pool settings falling back to the defaults", func() {
+			o := QuizOptionsFor(pool, QuizConfig{})
+			Expect(o.TotalQuestions).To(Equal(5))
+			Expect(o.QuestionTimeoutSec).To(Equal(20))
+			Expect(o.MinDifficulty).To(Equal(DefaultMinDifficulty))
+			Expect(o.MaxDifficulty).To(Equal(DefaultMaxDifficulty))
+			Expect(len(o.AvailableQuestions)).To(Equal(1))
+		})
+
+		It("lets the overrides take precedence", func() {
+			o := QuizOptionsFor(pool, QuizConfig{TotalQuestions: 3, MaxDifficulty: 4})
+			Expect(o.TotalQuestions).To(Equal(3))
+			Expect(o.QuestionTimeoutSec).To(Equal(20))
+			Expect(o.MaxDifficulty).To(Equal(4))
+		})
+	})
+
+	Describe("#Validate", func() {
+		It("returns no error when the quiz can be built", func() {
+			Expect(opts.Validate()).ToNot(HaveOccurred())
+		})
+
+		It("returns an error when there are not enough questions", func() {
+			opts.TotalQuestions = 100
+			Expect(opts.Validate()).To(MatchError(ContainSubstring("not enough questions")))
+		})
+
+		It("returns an error when the difficulty range is inverted", func() {
+			opts.MinDifficulty = 5
+			opts.MaxDifficulty = 2
+			Expect(opts.Validate()).To(MatchError(ContainSubstring("greater than max difficulty")))
+		})
+
+		It("returns an error when the difficulty is out of range", func() {
+			opts.MinDifficulty = -3
+			Expect(opts.Validate()).To(MatchError(ContainSubstring("difficulty -3 is outside 1-10")))
+
+			opts.MinDifficulty = 1
+			opts.MaxDifficulty = 11
+			Expect(opts.Validate()).To(MatchError(ContainSubstring("difficulty 11 is outside 1-10")))
+		})
+	})
+
 	Describe("#PersistForSessionEmail", func() {
 		var quiz Quiz
 		var email string
@@ -78,6 +232,48 @@ var _ = Describe("Quiz", func() {
 			Expect(len(session.Questions)).To(Equal(4))
 		})
 
+		It("stores the scoring configuration on the session", func() {
+			Expect(db.Create(&Session{Email: "jane.doe@example.com"}).Error).ToNot(HaveOccurred())
+			quiz.Scoring = ScoringConfig{Mode: PointsScoring, SpeedBonus: 0.5}
+			Expect(quiz.PersistForSessionEmail(db, "jane.doe@example.com")).ToNot(HaveOccurred())
+
+			jane, err := SessionForEmail(db, "jane.doe@example.com")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(jane.Scoring.Mode).To(Equal(PointsScoring))
+			Expect(jane.Scoring.SpeedBonus).To(Equal(0.5))
+		})
+
+		It("doesn't add questions to a session that already has them", func() {
+			again, err := NewQuizWithOpts(opts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(again.PersistForSessionEmail(db, email)).To(Succeed())
+
+			var count int64
+			Expect(db.Model(&Question{}).Count(&count).Error).ToNot(HaveOccurred())
+			Expect(count).To(Equal(int64(4)))
+		})
+
+		It("persists only one quiz when two requests start it at the same time", func() {
+			jane := Session{Email: "jane.doe@example.com"}
+			Expect(db.Create(&jane).Error).ToNot(HaveOccurred())
+
+			errs := make(chan error, 2)
+			for i := 0; i < 2; i++ {
+				q, err := NewQuizWithOpts(opts)
+				Expect(err).ToNot(HaveOccurred())
+				go func() {
+					defer GinkgoRecover()
+					errs <- q.PersistForSessionEmail(db, jane.Email)
+				}()
+			}
+			Expect(<-errs).To(Succeed())
+			Expect(<-errs).To(Succeed())
+
+			var count int64
+			Expect(db.Model(&Question{}).Where("session_id = ?", jane.ID).Count(&count).Error).ToNot(HaveOccurred())
+			Expect(count).To(Equal(int64(4)))
+		})
+
 		It("adds a unique index to each question", func() {
 			Expect(db.Preload(clause.Associations).Find(&session).Error).ToNot(HaveOccurred())
 			indices := []int{}
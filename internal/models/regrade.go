@@ -0,0 +1,180 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RegradeResult describes the effect of Regrade
+type RegradeResult struct {
+	UpdatedQuestions int
+	UpdatedSessions  int
+	// Leaderboards of completed sessions before and after regrading
+	Before []Session
+	After  []Session
+}
+
+// VoidDecision is the decision of an admin to void (or restore) a pool
+// question on a running server (see SetVoided). It takes precedence over the
+// `voided` value of the pool so that a later Regrade doesn't undo it.
+type VoidDecision struct {
+	PoolID    string `gorm:"primaryKey"`
+	Voided    bool
+	UpdatedAt time.Time
+}
+
+// ApplyVoidDecisions returns a copy of the pool where the questions with a
+// VoidDecision are voided (or restored) as decided.
+func ApplyVoidDecisions(db *gorm.DB, pool QuestionPool) (QuestionPool, error) {
+	var decisions []VoidDecision
+	if err := db.Find(&decisions).Error; err != nil {
+		return pool, fmt.Errorf("looking up void decisions: %w", err)
+	}
+	voided := map[string]bool{}
+	for _, d := range decisions {
+		voided[d.PoolID] = d.Voided
+	}
+
+	pool.Questions = append(QuestionList{}, pool.Questions...)
+	for i, q := range pool.Questions {
+		if v, found := voided[q.PoolID]; found {
+			pool.Questions[i].Voided = v
+		}
+	}
+
+	return pool, nil
+}
+
+// Regrade copies the answer key of every pool question to the persisted
+// questions with the same PoolID and recalculates the score of the affected
+// sessions. Everything happens in a single transaction. The questions are
+// voided as in the pool, unless an admin decided otherwise (see SetVoided).
+func Regrade(db *gorm.DB, pool QuestionPool) (RegradeResult, error) {
+	result := RegradeResult{}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if result.Before, err = CompletedLeaderboard(tx); err != nil {
+			return err
+		}
+		if pool, err = ApplyVoidDecisions(tx, pool); err != nil {
+			return err
+		}
+
+		poolQuestions := map[string]Question{}
+		ids := []string{}
+		for _, q := range pool.Questions {
+			poolQuestions[q.PoolID] = q
+			ids = append(ids, q.PoolID)
+		}
+
+		var persisted QuestionList
+		if err := tx.Where("pool_id IN ?", ids).Find(&persisted).Error; err != nil {
+			return fmt.Errorf("looking up persisted questions: %w", err)
+		}
+
+		affectedSessions := map[uint]bool{}
+		for _, q := range persisted {
+			current := poolQuestions[q.PoolID]
+			if current.AnswerKey() == q.AnswerKey() && current.Voided == q.Voided {
+				continue
+			}
+			q.CopyAnswerKey(current)
+			if err := tx.Save(&q).Error; err != nil {
+				return fmt.Errorf("updating question %d: %w", q.ID, err)
+			}
+			result.UpdatedQuestions++
+			affectedSessions[q.SessionID] = true
+		}
+
+		if result.UpdatedSessions, err = recalculateSessions(tx, affectedSessions); err != nil {
+			return err
+		}
+
+		result.After, err = CompletedLeaderboard(tx)
+		return err
+	})
+
+	return result, err
+}
+
+// SetVoided voids (or restores) all the persisted copies of the pool question
+// with the given id and recalculates the affected sessions. Voided questions
+// count for nobody. The decision is stored as a VoidDecision, so it also
+// applies to new quizzes and survives a Regrade.
+func SetVoided(db *gorm.DB, poolID string, voided bool) (RegradeResult, error) {
+	result := RegradeResult{}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if result.Before, err = CompletedLeaderboard(tx); err != nil {
+			return err
+		}
+
+		decision := VoidDecision{PoolID: poolID, Voided: voided}
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&decision).Error; err != nil {
+			return fmt.Errorf("saving the void decision: %w", err)
+		}
+
+		var persisted QuestionList
+		if err := tx.Where("pool_id = ? AND voided = ?", poolID, !voided).Find(&persisted).Error; err != nil {
+			return fmt.Errorf("looking up persisted questions: %w", err)
+		}
+
+		affectedSessions := map[uint]bool{}
+		for _, q := range persisted {
+			q.Voided = voided
+			if err := tx.Save(&q).Error; err != nil {
+				return fmt.Errorf("updating question %d: %w", q.ID, err)
+			}
+			result.UpdatedQuestions++
+			affectedSessions[q.SessionID] = true
+		}
+
+		if result.UpdatedSessions, err = recalculateSessions(tx, affectedSessions); err != nil {
+			return err
+		}
+
+		result.After, err = CompletedLeaderboard(tx)
+		return err
+	})
+
+	return result, err
+}
+
+// recalculateSessions runs UpdateCacheColumns for the sessions with the given
+// ids and returns how many were updated.
+func recalculateSessions(tx *gorm.DB, ids map[uint]bool) (int, error) {
+	updated := 0
+	for id := range ids {
+		var s Session
+		if err := tx.Preload(clause.Associations).First(&s, id).Error; err != nil {
+			return updated, fmt.Errorf("looking up session %d: %w", id, err)
+		}
+		s.UpdateCacheColumns()
+		if err := s.SaveCacheColumns(tx); err != nil {
+			return updated, fmt.Errorf("updating session %s: %w", s.Email, err)
+		}
+		updated++
+	}
+
+	return updated, nil
+}
+
+// CopyAnswerKey sets all the fields that decide whether an answer is right
+// (see AnswerKey) to the values of the given question.
+func (q *Question) CopyAnswerKey(from Question) {
+	q.Type = from.Type
+	q.RightAnswer = from.RightAnswer
+	q.RightAnswers = from.RightAnswers
+	q.Answers = from.Answers
+	q.Scoring = from.Scoring
+	q.AcceptedAnswers = from.AcceptedAnswers
+	q.AnswerPattern = from.AnswerPattern
+	q.CaseSensitive = from.CaseSensitive
+	q.Tolerance = from.Tolerance
+	q.Voided = from.Voided
+}
@@ -0,0 +1,123 @@
+package models_test
+
+import (
+	"time"
+
+	. "github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Regrade", func() {
+	var pool QuestionPool
+
+	BeforeEach(func() {
+		var err error
+		pool, err = NewQuestionPool(`
+questions:
+  - id: q1
+    text: Question 1
+    rightAnswer: 1
+    answers: [a, b]
+  - id: q2
+    text: Question 2
+    rightAnswer: 1
+    answers: [a, b]
+`)
+		Expect(err).ToNot(HaveOccurred())
+
+		// alice answered 1 and 1, bob answered 2 and 1
+		for email, answers := range map[string][]int{
+			"alice@example.com": {1, 1},
+			"bob@example.com":   {2, 1},
+		} {
+			session := Session{Email: email, Nickname: email}
+			for i, q := range pool.Questions {
+				q.UserAnswer = answers[i]
+				q.StartedAt = time.Now().Add(-time.Minute)
+				session.Questions = append(session.Questions, q)
+			}
+			session.UpdateCacheColumns()
+			Expect(db.Create(&session).Error).ToNot(HaveOccurred())
+		}
+	})
+
+	It("doesn't change anything when the answer keys are the same", func() {
+		result, err := Regrade(db, pool)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.UpdatedQuestions).To(BeZero())
+		Expect(result.UpdatedSessions).To(BeZero())
+		Expect(result.After).To(Equal(result.Before))
+	})
+
+	It("updates the answer key and the scores of the affected sessions", func() {
+		pool.Questions[0].RightAnswer = 2
+
+		result, err := Regrade(db, pool)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.UpdatedQuestions).To(Equal(2))
+		Expect(result.UpdatedSessions).To(Equal(2))
+
+		Expect(result.Before[0].Email).To(Equal("alice@example.com"))
+		Expect(result.Before[0].Score).To(Equal(100))
+		Expect(result.After[0].Email).To(Equal("bob@example.com"))
+		Expect(result.After[0].Score).To(Equal(100))
+		Expect(result.After[1].Score).To(Equal(50))
+
+		alice, err := SessionForEmail(db, "alice@example.com")
+		Expect(err).ToNot(HaveOccurred())
+		var q Question
+		Expect(db.First(&q, "pool_id = ? AND session_id = ?", "q1", alice.ID).Error).ToNot(HaveOccurred())
+		Expect(q.RightAnswer).To(Equal(2))
+	})
+
+	Describe("SetVoided", func() {
+		It("voids the question for everyone and recalculates the scores", func() {
+			result, err := SetVoided(db, "q1", true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.UpdatedQuestions).To(Equal(2))
+			Expect(result.UpdatedSessions).To(Equal(2))
+
+			// both answered q2 correctly
+			Expect(result.After[0].Score).To(Equal(100))
+			Expect(result.After[1].Score).To(Equal(100))
+
+			result, err = SetVoided(db, "q1", false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.UpdatedQuestions).To(Equal(2))
+			Expect(result.After[1].Score).To(Equal(50))
+		})
+
+		It("keeps the decision of the admin when regrading", func() {
+			_, err := SetVoided(db, "q1", true)
+			Expect(err).ToNot(HaveOccurred())
+
+			result, err := Regrade(db, pool)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.UpdatedQuestions).To(BeZero())
+			Expect(result.After[1].Score).To(Equal(100))
+
+			// restoring a question that is voided in the pool
+			pool.Questions[1].Voided = true
+			_, err = SetVoided(db, "q2", false)
+			Expect(err).ToNot(HaveOccurred())
+			result, err = Regrade(db, pool)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.UpdatedQuestions).To(BeZero())
+
+			var voided int64
+			Expect(db.Model(&Question{}).Where("voided = ?", true).Count(&voided).Error).ToNot(HaveOccurred())
+			Expect(voided).To(Equal(int64(2))) // q1 of both sessions
+		})
+
+		It("applies to new quizzes", func() {
+			_, err := SetVoided(db, "q1", true)
+			Expect(err).ToNot(HaveOccurred())
+
+			applied, err := ApplyVoidDecisions(db, pool)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(questionTextFromQuestionList(applied.Questions.NotVoided())).To(HaveExactElements("Question 2"))
+			Expect(pool.Questions[0].Voided).To(BeFalse()) // the pool is not changed
+		})
+	})
+})
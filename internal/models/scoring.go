@@ -0,0 +1,100 @@
+package models
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	// PercentageScoring ranks sessions by the percentage of right answers.
+	// It's the default.
+	PercentageScoring = "percentage"
+	// PointsScoring ranks sessions by points. Every right answer earns points
+	// scaled by the difficulty of the question and by how fast it was answered.
+	PointsScoring = "points"
+
+	DefaultPointsPerDifficulty = 100
+)
+
+// ScoringConfig is the `scoring` part of the quiz configuration. It's stored
+// with every session when the quiz is created, so that changing the question
+// pool doesn't change how existing sessions are scored.
+type ScoringConfig struct {
+	Mode string `yaml:"mode,omitempty"`
+	// PointsPerDifficulty is what a right answer earns per difficulty level
+	// (before the speed bonus).
+	PointsPerDifficulty int `yaml:"pointsPerDifficulty,omitempty"`
+	// SpeedBonus is the fraction of the points added for an instant answer.
+	// It decreases linearly to 0 as the allowed time runs out. E.g. with 0.5
+	// an instant answer earns 150% of the points.
+	SpeedBonus float64 `yaml:"speedBonus,omitempty"`
+}
+
+// Merge returns a copy of the config where every field that is set in
+// `override` replaces the current value.
+func (c ScoringConfig) Merge(override ScoringConfig) ScoringConfig {
+	if override.Mode != "" {
+		c.Mode = override.Mode
+	}
+	if override.PointsPerDifficulty != 0 {
+		c.PointsPerDifficulty = override.PointsPerDifficulty
+	}
+	if override.SpeedBonus != 0 {
+		c.SpeedBonus = override.SpeedBonus
+	}
+
+	return c
+}
+
+func (c ScoringConfig) Validate() error {
+	switch c.Mode {
+	case "", PercentageScoring, PointsScoring:
+	default:
+		return errors.New("scoring mode should be either \"percentage\" or \"points\"")
+	}
+	if c.PointsPerDifficulty < 0 {
+		return errors.New("points per difficulty can't be negative")
+	}
+	if c.SpeedBonus < 0 {
+		return errors.New("speed bonus can't be negative")
+	}
+
+	return nil
+}
+
+func (c ScoringConfig) UsesPoints() bool {
+	return c.Mode == PointsScoring
+}
+
+// Points returns the points earned by the answer to the given question.
+// Questions of difficulty 0 (not set) are treated as difficulty 1.
+func (c ScoringConfig) Points(q Question) int {
+	credit := q.Credit()
+	if credit == 0 || q.Voided {
+		return 0
+	}
+
+	perDifficulty := c.PointsPerDifficulty
+	if perDifficulty == 0 {
+		perDifficulty = DefaultPointsPerDifficulty
+	}
+	difficulty := q.Difficulty
+	if difficulty < 1 {
+		difficulty = 1
+	}
+
+	return int(math.Round(credit * float64(perDifficulty*difficulty) * (1 + c.SpeedBonus*q.TimeLeftRatio())))
+}
+
+// TimeLeftRatio returns the fraction of the allowed time that was left when
+// the question was answered (1 for an instant answer, 0 at the deadline).
+func (q Question) TimeLeftRatio() float64 {
+	if q.AnsweredAt.IsZero() || q.StartedAt.IsZero() || q.AllowedSeconds <= 0 {
+		return 0
+	}
+
+	elapsed := q.AnsweredAt.Sub(q.StartedAt).Seconds()
+	ratio := 1 - elapsed/float64(q.AllowedSeconds)
+
+	return math.Max(0, math.Min(1, ratio))
+}
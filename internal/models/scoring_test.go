@@ -0,0 +1,60 @@
+package models_test
+
+import (
+	"time"
+
+	. "github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ScoringConfig", func() {
+	var config ScoringConfig
+	var question Question
+	var startedAt time.Time
+
+	BeforeEach(func() {
+		config = ScoringConfig{Mode: PointsScoring, PointsPerDifficulty: 10, SpeedBonus: 1}
+		startedAt = time.Now().Add(-time.Hour)
+		question = Question{
+			Difficulty:     3,
+			RightAnswer:    1,
+			UserAnswer:     1,
+			Answers:        Answers{"a", "b"},
+			AllowedSeconds: 20,
+			StartedAt:      startedAt,
+		}
+	})
+
+	Describe("#Points", func() {
+		It("scales the points by difficulty and speed", func() {
+			question.AnsweredAt = startedAt // instant answer, double points
+			Expect(config.Points(question)).To(Equal(60))
+
+			question.AnsweredAt = startedAt.Add(10 * time.Second) // half the time left
+			Expect(config.Points(question)).To(Equal(45))
+
+			question.AnsweredAt = startedAt.Add(30 * time.Second) // no bonus after the deadline
+			Expect(config.Points(question)).To(Equal(30))
+		})
+
+		It("returns 0 for wrong answers", func() {
+			question.UserAnswer = 2
+			question.AnsweredAt = startedAt
+			Expect(config.Points(question)).To(Equal(0))
+		})
+
+		It("uses the default points when not configured", func() {
+			config = ScoringConfig{Mode: PointsScoring}
+			question.AnsweredAt = startedAt
+			Expect(config.Points(question)).To(Equal(300))
+		})
+	})
+
+	Describe("#Validate", func() {
+		It("rejects unknown modes", func() {
+			config.Mode = "stars"
+			Expect(config.Validate()).To(HaveOccurred())
+		})
+	})
+})
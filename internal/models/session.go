@@ -2,23 +2,40 @@ package models
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Session struct {
 	gorm.Model
-	Email     string
-	Nickname  string
-	Score     int
-	Complete  bool
-	Questions []Question `gorm:"foreignKey:SessionEmail;references:Email"`
+	Email    string `gorm:"uniqueIndex"` // a database holds the sessions of a single event
+	Nickname string
+	Score    int           // percentage of right answers
+	Points   int           // only used with the "points" scoring mode
+	Scoring  ScoringConfig `gorm:"embedded;embeddedPrefix:scoring_"`
+	Complete bool
+	// Disqualified sessions are hidden from the leaderboard (e.g. cheaters)
+	Disqualified bool
+	// Used to break ties on the leaderboard (see RankSessions)
+	TotalAnswerTime time.Duration
+	CompletedAt     time.Time `gorm:"serializer:nulltime"`
+	Rank            int       `gorm:"-"` // set by RankSessions
+	Questions       []Question
 }
 
+// ErrEmailUsed is returned when creating a session with the email of another
+// session
+var ErrEmailUsed = errors.New("email has already been used")
+
 var emailRegex = regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}$`)
 
 func NewSession(db *gorm.DB, email, nickname string) (Session, error) {
@@ -27,9 +44,15 @@ func NewSession(db *gorm.DB, email, nickname string) (Session, error) {
 	if !ValidEmail(email) {
 		return session, errors.New("invalid email")
 	}
+	if utf8.RuneCountInString(nickname) > MaxNicknameLength {
+		return session, ErrNicknameTooLong
+	}
 
 	result := db.Create(&session)
 	if err := result.Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) { // e.g. two concurrent requests with the same email
+			return session, fmt.Errorf("%w: %s", ErrEmailUsed, email)
+		}
 		return session, err
 	}
 
@@ -73,15 +96,16 @@ func (s Session) CurrentQuestion() (Question, error) {
 	// otherwise it will expire while answering another one. This should not happen
 	// if the questions are presented in order of Index but this code handles this
 	// anyway.
+	// Voided questions count for nobody so they are never asked.
 	for _, q := range s.Questions {
-		if !q.StartedAt.IsZero() && q.UserAnswer == 0 && !q.Expired() {
+		if !q.StartedAt.IsZero() && !q.Answered() && !q.Expired() && !q.Voided {
 			return q, nil
 		}
 	}
 
 	// return the first unanswered question by Index
 	for _, q := range s.Questions {
-		if q.UserAnswer == 0 && !q.Expired() {
+		if !q.Answered() && !q.Expired() && !q.Voided {
 			return q, nil
 		}
 	}
@@ -90,37 +114,218 @@ func (s Session) CurrentQuestion() (Question, error) {
 	return Question{}, nil
 }
 
-// UpdateCacheColumns calculates the current "Score" value based only on
+// ExpireQuestions records the expiry (ExpiredAt) of the questions that ran
+// out of time. When `all` is true, the questions that were not answered yet
+// are expired too, started or not. It returns the number of expired questions.
+func (s *Session) ExpireQuestions(db *gorm.DB, now time.Time, all bool) (int, error) {
+	expired := 0
+	for i, q := range s.Questions {
+		if !q.ExpiredAt.IsZero() || q.Answered() || q.Voided {
+			continue
+		}
+		switch {
+		case q.Expired():
+			q.ExpiredAt = q.Deadline()
+		case all:
+			q.ExpiredAt = now
+		default:
+			continue
+		}
+		if err := db.Model(&q).Update("expired_at", q.ExpiredAt).Error; err != nil {
+			return expired, fmt.Errorf("updating question %d: %w", q.ID, err)
+		}
+		s.Questions[i] = q
+		expired++
+	}
+
+	return expired, nil
+}
+
+// Reset deletes the questions and the score of the session, so that the
+// participant starts over with new questions the next time they open the quiz.
+func (s *Session) Reset(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("session_id = ?", s.ID).Delete(&Question{}).Error; err != nil {
+			return fmt.Errorf("deleting the questions of %s: %w", s.Email, err)
+		}
+
+		s.Questions = nil
+		s.Score = 0
+		s.Points = 0
+		s.Complete = false
+		s.TotalAnswerTime = 0
+		s.CompletedAt = time.Time{}
+		if err := s.SaveCacheColumns(tx); err != nil {
+			return fmt.Errorf("resetting session %s: %w", s.Email, err)
+		}
+
+		return nil
+	})
+}
+
+// Delete deletes the session and its questions for good, so that the email
+// can be used again.
+func (s Session) Delete(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("session_id = ?", s.ID).Delete(&Question{}).Error; err != nil {
+			return fmt.Errorf("deleting the questions of %s: %w", s.Email, err)
+		}
+		if err := tx.Unscoped().Delete(&s).Error; err != nil {
+			return fmt.Errorf("deleting session %s: %w", s.Email, err)
+		}
+
+		return nil
+	})
+}
+
+// SetDisqualified hides the session from the leaderboard (or shows it again)
+func (s *Session) SetDisqualified(db *gorm.DB, disqualified bool) error {
+	if err := db.Model(s).Update("disqualified", disqualified).Error; err != nil {
+		return fmt.Errorf("updating session %s: %w", s.Email, err)
+	}
+
+	return nil
+}
+
+// Refresh reloads the session with its questions, expires the ones that ran
+// out of time (see ExpireQuestions) and saves the recalculated cached columns.
+// Sessions with no activity for `abandonAfter` are considered abandoned (0
+// disables it, see SweepSessions). Everything happens in a transaction that
+// locks the session first, so that concurrent refreshes (e.g. after an answer
+// and by the sweeper) can't save a score calculated from outdated questions.
+// It returns the number of expired questions and whether the score or the
+// completion changed.
+func (s *Session) Refresh(db *gorm.DB, now time.Time, abandonAfter time.Duration) (int, bool, error) {
+	expired, changed := 0, false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := lockSession(tx, s.ID); err != nil {
+			return err
+		}
+		if err := tx.Preload(clause.Associations).First(s, s.ID).Error; err != nil {
+			return fmt.Errorf("looking up session %d: %w", s.ID, err)
+		}
+		if len(s.Questions) == 0 {
+			return nil // the quiz is being created
+		}
+
+		abandoned := abandonAfter > 0 && s.Abandoned(now.Add(-abandonAfter))
+		var err error
+		if expired, err = s.ExpireQuestions(tx, now, abandoned); err != nil {
+			return err
+		}
+
+		before := *s
+		s.UpdateCacheColumns()
+		changed = s.Complete != before.Complete || s.Score != before.Score || s.Points != before.Points
+		if err := s.SaveCacheColumns(tx); err != nil {
+			return fmt.Errorf("updating session %s: %w", s.Email, err)
+		}
+
+		return nil
+	})
+
+	return expired, changed, err
+}
+
+// lockSession locks the row of the session until the end of the transaction.
+// A no-op update does it in all the supported databases (SQLite has no row
+// locks, it locks the whole database for writing instead).
+func lockSession(tx *gorm.DB, id uint) error {
+	if err := tx.Exec("UPDATE sessions SET id = id WHERE id = ?", id).Error; err != nil {
+		return fmt.Errorf("locking session %d: %w", id, err)
+	}
+
+	return nil
+}
+
+// SaveCacheColumns writes the columns calculated by UpdateCacheColumns. The
+// rest of the row is left alone, so that changes made since the session was
+// loaded (e.g. a disqualification by an admin) are not undone.
+func (s *Session) SaveCacheColumns(db *gorm.DB) error {
+	return db.Model(s).Updates(map[string]interface{}{
+		"score":             s.Score,
+		"points":            s.Points,
+		"complete":          s.Complete,
+		"completed_at":      nullTime(s.CompletedAt),
+		"total_answer_time": s.TotalAnswerTime,
+	}).Error
+}
+
+// UpdateCacheColumns calculates the current "Score" and "Points" values based only on
 // answered and expired questions. Expired questions with no answer
-// are considered "wrong".
+// are considered "wrong". Voided questions don't count at all.
+// The "TotalAnswerTime" and "CompletedAt" columns are used to break ties.
 // It also calculated the value of the "Completed" column. A session is complete
-// when all questions are answered or expired.
+// when all questions are answered, expired or voided.
 func (s *Session) UpdateCacheColumns() {
-	correctAnswers := 0
+	correctAnswers := 0.0
+	points := 0
+	var totalAnswerTime time.Duration
+	var lastFinishedAt time.Time
 	completeQuestions := 0
+	scoredQuestions := 0
 	totalQuestions := len(s.Questions)
 
 	for _, q := range s.Questions {
+		if q.Voided {
+			completeQuestions++ // never asked, never scored
+			continue
+		}
+
 		if q.Expired() {
 			completeQuestions++ // consider it a wrong answer
+			scoredQuestions++
+			totalAnswerTime += time.Duration(q.AllowedSeconds) * time.Second
+			lastFinishedAt = latest(lastFinishedAt, q.Deadline())
 			continue
 		}
 
 		// ignore not started or in-progress questions (we already handled expired above)
-		if q.StartedAt.IsZero() || q.UserAnswer == 0 {
+		if q.StartedAt.IsZero() || !q.Answered() {
 			continue
 		}
 
-		if q.UserAnswer == q.RightAnswer {
-			correctAnswers++
-		}
+		correctAnswers += q.Credit()
+		points += s.Scoring.Points(q)
 		completeQuestions++ // right or wrong, count it in
+		scoredQuestions++
+		if !q.AnsweredAt.IsZero() { // not recorded for older answers
+			totalAnswerTime += q.AnsweredAt.Sub(q.StartedAt)
+			lastFinishedAt = latest(lastFinishedAt, q.AnsweredAt)
+		}
 	}
 
+	s.TotalAnswerTime = totalAnswerTime
 	if completeQuestions == totalQuestions {
 		s.Complete = true
+		s.CompletedAt = lastFinishedAt
+	}
+	s.Points = points
+	if scoredQuestions == 0 { // nothing answered yet
+		s.Score = 0
+		return
+	}
+	s.Score = int(math.Round(correctAnswers / float64(scoredQuestions) * 100))
+}
+
+// RankingScore returns the value sessions are ranked by: the points when the
+// session uses the "points" scoring mode, the percentage otherwise.
+func (s Session) RankingScore() int {
+	if s.Scoring.UsesPoints() {
+		return s.Points
 	}
-	s.Score = int(math.Round(float64(correctAnswers) / float64(completeQuestions) * 100))
+
+	return s.Score
+}
+
+// ScoreLabel returns the ranking score for display, e.g. "80%" or "1250 pts"
+func (s Session) ScoreLabel() string {
+	if s.Scoring.UsesPoints() {
+		return strconv.Itoa(s.Points) + " pts"
+	}
+
+	return strconv.Itoa(s.Score) + "%"
 }
 
 // EmailObfuscated obfuscates an email address by replacing characters with dots,
@@ -160,3 +365,10 @@ func obfuscateDomain(domain string) string {
 
 	return strings.Join(domainParts, ".")
 }
+
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
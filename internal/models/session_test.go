@@ -1,6 +1,7 @@
 package models_test
 
 import (
+	"strings"
 	"time"
 
 	. "github.com/jimmykarily/quizmaker/internal/models"
@@ -14,6 +15,16 @@ var _ = Describe("Session", func() {
 		session = Session{}
 	})
 
+	Describe("NewSession", func() {
+		It("rejects nicknames longer than MaxNicknameLength", func() {
+			_, err := NewSession(db, "alice@example.com", strings.Repeat("a", MaxNicknameLength+1))
+			Expect(err).To(MatchError(ErrNicknameTooLong))
+
+			_, err = NewSession(db, "alice@example.com", strings.Repeat("ä", MaxNicknameLength))
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
 	Describe("#HasExpiredQuestions", func() {
 		When("there are expired questions", func() {
 			BeforeEach(func() {
@@ -109,6 +120,21 @@ var _ = Describe("Session", func() {
 				Expect(q.Index).To(Equal(2))
 			})
 		})
+		When("there is a voided question", func() {
+			BeforeEach(func() {
+				session.Questions = []Question{
+					{Text: "voided question", AllowedSeconds: 10, Index: 1, Voided: true},
+					{Text: "next question", AllowedSeconds: 10, Index: 2},
+				}
+			})
+
+			It("skips it", func() {
+				q, err := session.CurrentQuestion()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(q.Text).To(Equal("next question"))
+			})
+		})
+
 		When("there are no expired questions", func() {
 			When("there is a started question", func() {
 				BeforeEach(func() {
@@ -252,6 +278,26 @@ var _ = Describe("Session", func() {
 			Expect(session.Complete).To(BeFalse())
 		})
 
+		It("updates the Points field", func() {
+			session.Scoring = ScoringConfig{Mode: PointsScoring, PointsPerDifficulty: 10}
+			session.UpdateCacheColumns()
+			Expect(session.Points).To(Equal(10)) // one right answer without speed bonus
+			Expect(session.RankingScore()).To(Equal(10))
+			Expect(session.ScoreLabel()).To(Equal("10 pts"))
+		})
+
+		It("ignores voided questions", func() {
+			session.Questions[4].Voided = true // the wrongly answered one
+			session.UpdateCacheColumns()
+			Expect(session.Score).To(Equal(50))
+
+			// voided questions don't need an answer to complete the session
+			session.Questions[1].Voided = true
+			session.Questions[2].Voided = true
+			session.UpdateCacheColumns()
+			Expect(session.Complete).To(BeTrue())
+		})
+
 		It("updated the Complete field", func() {
 			for i := range session.Questions {
 				session.Questions[i].StartedAt = time.Now().Add(-10 * time.Second)
@@ -261,5 +307,60 @@ var _ = Describe("Session", func() {
 			session.UpdateCacheColumns()
 			Expect(session.Complete).To(BeTrue())
 		})
+
+		It("updates the tie breaking fields", func() {
+			startedAt := time.Now().Add(-10 * time.Second)
+			for i := range session.Questions {
+				session.Questions[i].StartedAt = startedAt
+				session.Questions[i].UserAnswer = 2
+				session.Questions[i].AnsweredAt = startedAt.Add(time.Duration(i+1) * time.Second)
+			}
+			session.UpdateCacheColumns()
+			Expect(session.TotalAnswerTime).To(Equal(15 * time.Second))
+			Expect(session.CompletedAt).To(Equal(startedAt.Add(5 * time.Second)))
+		})
+	})
+
+	Describe("#Reset and #Delete", func() {
+		BeforeEach(func() {
+			var err error
+			session, err = NewSession(db, "alice@example.com", "alice")
+			Expect(err).ToNot(HaveOccurred())
+			quiz := Quiz{Questions: []Question{
+				{Text: "Q1", Answers: Answers{"a", "b"}, RightAnswer: 1, UserAnswer: 1,
+					StartedAt: time.Now(), AnsweredAt: time.Now(), AllowedSeconds: 30},
+			}}
+			Expect(quiz.PersistForSessionEmail(db, session.Email)).To(Succeed())
+			session, err = SessionForEmail(db, session.Email)
+			Expect(err).ToNot(HaveOccurred())
+			session.Complete = true
+			session.Score = 100
+			Expect(db.Save(&session).Error).To(Succeed())
+		})
+
+		It("deletes the questions and the score", func() {
+			Expect(session.Reset(db)).To(Succeed())
+
+			reloaded, err := SessionForEmail(db, session.Email)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reloaded.Complete).To(BeFalse())
+			Expect(reloaded.Score).To(BeZero())
+			var count int64
+			Expect(db.Unscoped().Model(&Question{}).Count(&count).Error).To(Succeed())
+			Expect(count).To(BeZero())
+		})
+
+		It("deletes the session so that the email can be used again", func() {
+			Expect(session.Delete(db)).To(Succeed())
+
+			_, err := SessionForEmail(db, session.Email)
+			Expect(err).To(HaveOccurred())
+			var count int64
+			Expect(db.Unscoped().Model(&Question{}).Count(&count).Error).To(Succeed())
+			Expect(count).To(BeZero())
+
+			_, err = NewSession(db, session.Email, "alice again")
+			Expect(err).ToNot(HaveOccurred())
+		})
 	})
 })
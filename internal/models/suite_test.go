@@ -8,7 +8,6 @@ import (
 	"github.com/jimmykarily/quizmaker/internal/models"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -19,16 +18,31 @@ func TestConfig(t *testing.T) {
 	RunSpecs(t, "Models Suite")
 }
 
-// reset the db before each test
+// reset the db before each test. Set QUIZMAKER_TEST_DATABASE_URL to run the
+// tests against another database (e.g. postgres://localhost/quizmaker_test),
+// all its tables are dropped.
 var _ = BeforeEach(func() {
-	testDbPath, err := filepath.Abs(filepath.Join("..", "..", "tests", "database.sql"))
-	Expect(err).ToNot(HaveOccurred())
-	err = os.RemoveAll(testDbPath)
+	databaseURL := os.Getenv("QUIZMAKER_TEST_DATABASE_URL")
+	if databaseURL == "" {
+		testDbPath, err := filepath.Abs(filepath.Join("..", "..", "tests", "database.sql"))
+		Expect(err).ToNot(HaveOccurred())
+		err = os.RemoveAll(testDbPath)
+		Expect(err).ToNot(HaveOccurred())
+		databaseURL = testDbPath
+	}
+
+	var err error
+	db, err = models.OpenDatabase(databaseURL)
 	Expect(err).ToNot(HaveOccurred())
 
-	db, err = gorm.Open(sqlite.Open(testDbPath), &gorm.Config{})
+	err = models.DropTables(db)
 	Expect(err).ToNot(HaveOccurred())
+	_, err = models.MigrateUp(db)
+	Expect(err).ToNot(HaveOccurred())
+})
 
-	err = models.AutoMigrate(db)
+var _ = AfterEach(func() {
+	sqlDB, err := db.DB()
 	Expect(err).ToNot(HaveOccurred())
+	Expect(sqlDB.Close()).To(Succeed())
 })
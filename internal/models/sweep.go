@@ -0,0 +1,133 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SweepResult describes the effect of SweepSessions
+type SweepResult struct {
+	ExpiredQuestions int
+	// Sessions whose cached columns changed, e.g. because they are now complete
+	UpdatedSessions []Session
+}
+
+// SweepSessions finds the incomplete sessions, records the expiry of the
+// questions that ran out of time and recalculates the cached columns (see
+// Refresh). Sessions with no question in progress and no activity for
+// `abandonAfter` are considered abandoned and all their remaining questions
+// are expired, so that they don't stay "in progress" forever. An
+// `abandonAfter` of 0 disables this.
+func SweepSessions(db *gorm.DB, abandonAfter time.Duration, now time.Time) (SweepResult, error) {
+	result := SweepResult{}
+
+	var sessions []Session
+	if err := db.Where("complete = ?", false).Find(&sessions).Error; err != nil {
+		return result, fmt.Errorf("looking up incomplete sessions: %w", err)
+	}
+
+	// every session is reloaded by Refresh because it may have changed (e.g.
+	// answered) since the lookup
+	for _, s := range sessions {
+		expired, changed, err := s.Refresh(db, now, abandonAfter)
+		if err != nil {
+			return result, err
+		}
+		result.ExpiredQuestions += expired
+		if changed {
+			result.UpdatedSessions = append(result.UpdatedSessions, s)
+		}
+	}
+
+	return result, nil
+}
+
+// LastActivity returns the last time something happened in the session: it
+// was created, a question was started, answered or expired.
+func (s Session) LastActivity() time.Time {
+	result := s.CreatedAt
+	for _, q := range s.Questions {
+		result = latest(result, q.StartedAt)
+		result = latest(result, q.AnsweredAt)
+		if q.Expired() {
+			result = latest(result, q.Deadline())
+		}
+	}
+
+	return result
+}
+
+// Abandoned returns true if the session has no question in progress and no
+// activity since the given time.
+func (s Session) Abandoned(since time.Time) bool {
+	for _, q := range s.Questions {
+		if !q.StartedAt.IsZero() && !q.Answered() && !q.Expired() && !q.Voided {
+			return false // still answering
+		}
+	}
+
+	return s.LastActivity().Before(since)
+}
+
+// SWEEPER_LEASE is the name of the Lease of the replica that sweeps
+const SWEEPER_LEASE = "sweeper"
+
+// Sweeper runs SweepSessions periodically (see Run)
+type Sweeper struct {
+	DB           *gorm.DB
+	Interval     time.Duration
+	AbandonAfter time.Duration
+	InfoLogger   *log.Logger
+	ErrorLogger  *log.Logger
+	// OnUpdate is called (when set) with the sessions updated by a sweep
+	OnUpdate func([]Session)
+	// Holder identifies the replica of the server. When set, the replicas
+	// take turns through a Lease and only the holder sweeps.
+	Holder string
+}
+
+// Run sweeps the sessions every `Interval` until the context is done. It
+// returns right away when `Interval` is 0.
+func (s Sweeper) Run(ctx context.Context) {
+	if s.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.Holder != "" {
+				// the lease outlives a tick, so that the holder keeps it
+				held, err := AcquireLease(s.DB.WithContext(ctx), SWEEPER_LEASE, s.Holder, 2*s.Interval, time.Now())
+				if err != nil && ctx.Err() == nil {
+					s.ErrorLogger.Printf("sweeping sessions: %s\n", err.Error())
+				}
+				if !held {
+					continue
+				}
+			}
+			result, err := SweepSessions(s.DB.WithContext(ctx), s.AbandonAfter, time.Now())
+			if err != nil {
+				if ctx.Err() == nil {
+					s.ErrorLogger.Printf("sweeping sessions: %s\n", err.Error())
+				}
+				continue
+			}
+			if result.ExpiredQuestions > 0 || len(result.UpdatedSessions) > 0 {
+				s.InfoLogger.Printf("sweeper expired %d question(s) and updated %d session(s)\n",
+					result.ExpiredQuestions, len(result.UpdatedSessions))
+			}
+			if len(result.UpdatedSessions) > 0 && s.OnUpdate != nil {
+				s.OnUpdate(result.UpdatedSessions)
+			}
+		}
+	}
+}
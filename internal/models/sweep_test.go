@@ -0,0 +1,142 @@
+package models_test
+
+import (
+	"time"
+
+	. "github.com/jimmykarily/quizmaker/internal/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("SweepSessions", func() {
+	var now time.Time
+
+	// creates a session with 3 questions, the first one answered correctly
+	// at `answeredAt` and the second one started at `startedAt` (if not zero)
+	createSession := func(email string, answeredAt, startedAt time.Time) {
+		session := Session{Email: email, Nickname: email}
+		session.CreatedAt = answeredAt.Add(-time.Minute)
+		for i := 1; i <= 3; i++ {
+			session.Questions = append(session.Questions, Question{
+				Index: i, Text: "question", RightAnswer: 1, Answers: Answers{"a", "b"}, AllowedSeconds: 30,
+			})
+		}
+		session.Questions[0].StartedAt = answeredAt.Add(-10 * time.Second)
+		session.Questions[0].AnsweredAt = answeredAt
+		session.Questions[0].UserAnswer = 1
+		session.Questions[1].StartedAt = startedAt
+		session.UpdateCacheColumns()
+		Expect(db.Create(&session).Error).ToNot(HaveOccurred())
+	}
+
+	reload := func(email string) Session {
+		session, err := SessionForEmail(db, email)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(db.Preload("Questions").Find(&session).Error).ToNot(HaveOccurred())
+		return session
+	}
+
+	BeforeEach(func() {
+		now = time.Now()
+	})
+
+	It("doesn't touch sessions in progress", func() {
+		createSession("active@example.com", now.Add(-time.Minute), now.Add(-10*time.Second))
+
+		result, err := SweepSessions(db, 10*time.Minute, now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.ExpiredQuestions).To(BeZero())
+		Expect(result.UpdatedSessions).To(BeEmpty())
+		Expect(reload("active@example.com").Complete).To(BeFalse())
+	})
+
+	It("records the expiry of questions that ran out of time", func() {
+		startedAt := now.Add(-2 * time.Minute)
+		createSession("late@example.com", now.Add(-3*time.Minute), startedAt)
+
+		result, err := SweepSessions(db, 10*time.Minute, now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.ExpiredQuestions).To(Equal(1))
+
+		session := reload("late@example.com")
+		Expect(session.Questions[1].ExpiredAt).To(BeTemporally("~", startedAt.Add(30*time.Second), time.Second))
+		Expect(session.Questions[2].ExpiredAt).To(BeZero())
+		Expect(session.Complete).To(BeFalse()) // the last question can still be answered
+		Expect(session.Score).To(Equal(50))
+	})
+
+	It("expires the remaining questions of abandoned sessions and completes them", func() {
+		createSession("gone@example.com", now.Add(-time.Hour), time.Time{})
+
+		result, err := SweepSessions(db, 10*time.Minute, now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.ExpiredQuestions).To(Equal(2))
+		Expect(result.UpdatedSessions).To(HaveLen(1))
+
+		session := reload("gone@example.com")
+		Expect(session.Complete).To(BeTrue())
+		Expect(session.Score).To(Equal(33))
+		Expect(session.Questions[2].Expired()).To(BeTrue())
+	})
+
+	It("doesn't consider sessions abandoned when abandonAfter is 0", func() {
+		createSession("gone@example.com", now.Add(-time.Hour), time.Time{})
+
+		result, err := SweepSessions(db, 0, now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.ExpiredQuestions).To(BeZero())
+		Expect(reload("gone@example.com").Complete).To(BeFalse())
+	})
+
+	It("doesn't undo changes made to the sessions during the sweep", func() {
+		createSession("cheater@example.com", now.Add(-time.Hour), time.Time{})
+
+		// an admin disqualifies the session right after the sweep loaded it
+		disqualified := false
+		err := db.Callback().Query().After("gorm:query").Register("disqualify", func(tx *gorm.DB) {
+			if _, ok := tx.Statement.Dest.(*[]Session); !ok || disqualified {
+				return
+			}
+			disqualified = true
+			Expect(db.Exec("UPDATE sessions SET disqualified = ?", true).Error).To(Succeed())
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		result, err := SweepSessions(db, 10*time.Minute, now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.UpdatedSessions).To(HaveLen(1))
+
+		session := reload("cheater@example.com")
+		Expect(session.Complete).To(BeTrue())
+		Expect(session.Disqualified).To(BeTrue())
+	})
+	It("recalculates the score from the answers given during the sweep", func() {
+		createSession("fast@example.com", now.Add(-time.Hour), time.Time{})
+
+		// the remaining questions are answered right after the sweep looked
+		// up the sessions
+		answered := false
+		err := db.Callback().Query().After("gorm:query").Register("answer", func(tx *gorm.DB) {
+			if _, ok := tx.Statement.Dest.(*[]Session); !ok || answered {
+				return
+			}
+			answered = true
+			Expect(db.Exec("UPDATE questions SET user_answer = 1, started_at = ?, answered_at = ? WHERE answered_at IS NULL",
+				now.Add(-time.Second), now).Error).To(Succeed())
+		})
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(func() { Expect(db.Callback().Query().Remove("answer")).To(Succeed()) })
+
+		result, err := SweepSessions(db, 10*time.Minute, now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.ExpiredQuestions).To(BeZero())
+
+		session := reload("fast@example.com")
+		Expect(session.Complete).To(BeTrue())
+		Expect(session.Score).To(Equal(100))
+		for _, q := range session.Questions {
+			Expect(q.ExpiredAt).To(BeZero())
+		}
+	})
+})
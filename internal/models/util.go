@@ -2,6 +2,9 @@ package models
 
 import "gorm.io/gorm"
 
-func AutoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(&Session{}, &Question{})
+// DropTables drops the tables of all the models, and the record of the
+// migrations (e.g. to start the tests with an empty database)
+func DropTables(db *gorm.DB) error {
+	return db.Migrator().DropTable(&SchemaMigration{}, &Session{}, &Question{},
+		&Game{}, &GameQuestion{}, &GamePlayer{}, &GameAnswer{}, &VoidDecision{}, &ChangeNotification{}, &Lease{})
 }
@@ -2,7 +2,10 @@ package settings
 
 import (
 	"log"
+	"time"
 
+	"github.com/jimmykarily/quizmaker/internal/events"
+	"github.com/jimmykarily/quizmaker/internal/models"
 	"gorm.io/gorm"
 )
 
@@ -10,7 +13,26 @@ type Settings struct {
 	InfoLogger       *log.Logger
 	WarningLogger    *log.Logger
 	ErrorLogger      *log.Logger
-	QuestionPoolFile string
-	DB               *gorm.DB
-	CookieSecret     string
+	QuestionPoolFile string // a file, a directory or a glob pattern
+	// QuestionPool holds the parsed QuestionPoolFile. When nil, the files are
+	// parsed on every use.
+	QuestionPool *models.QuestionPoolWatcher
+	DB           *gorm.DB
+	CookieSecret string
+	AdminToken   string
+	// QuizOverrides take precedence over the `quiz` section of the question pool
+	QuizOverrides models.QuizConfig
+	// AnswerGracePeriod is added to the deadline of every question when an
+	// answer is submitted, to allow for network latency
+	AnswerGracePeriod time.Duration
+	// Events pushes live updates (e.g. of the leaderboard) to the browsers.
+	// When nil, nothing is pushed.
+	Events *events.Broker
+	// GameEvents pushes the state of the live games, one topic per game code.
+	// When nil, nothing is pushed.
+	GameEvents *events.Topics
+	// NotifyReplicas records the changes that are pushed in the database too,
+	// so that the other replicas of the server push them to their own browsers
+	// (see models.NotificationRelay and controllers.RelayChange)
+	NotifyReplicas bool
 }
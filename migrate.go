@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jimmykarily/quizmaker/internal/models"
+)
+
+// runMigrate implements the "migrate" subcommand:
+//
+//	quizmaker migrate up     applies the pending migrations
+//	quizmaker migrate down   rolls back the latest migration
+//	quizmaker migrate status lists the migrations and whether they're applied
+//
+// It returns the exit code.
+func runMigrate(args []string) int {
+	action := ""
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	registerDatabaseFlags(fs)
+	fs.Parse(args)
+
+	db, err := connectDatabase()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid settings: %s\n", err.Error())
+		return 1
+	}
+
+	switch action {
+	case "up":
+		result, err := models.MigrateUp(db)
+		if result.Adopted {
+			fmt.Println("Adopted the existing database (created before versioned migrations) as the baseline")
+		}
+		for _, m := range result.Applied {
+			fmt.Printf("Applied migration %d (%s)\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Migrating failed: %s\n", err.Error())
+			return 1
+		}
+		if len(result.Applied) == 0 {
+			fmt.Println("The database is up to date")
+		}
+	case "down":
+		m, err := models.MigrateDown(db)
+		if errors.Is(err, models.ErrNoMigrationApplied) {
+			fmt.Fprintln(os.Stderr, "No migration to roll back")
+			return 1
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Rolling back failed: %s\n", err.Error())
+			return 1
+		}
+		fmt.Printf("Rolled back migration %d (%s)\n", m.Version, m.Name)
+	case "status":
+		statuses, err := models.MigrationStatuses(db)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Checking the migrations failed: %s\n", err.Error())
+			return 1
+		}
+		fmt.Printf("%-8s %-30s %s\n", "VERSION", "NAME", "APPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied {
+				applied = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%-8d %-30s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: quizmaker migrate up|down|status [flags]")
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jimmykarily/quizmaker/internal/models"
+)
+
+// runRegrade implements the "regrade" subcommand. It updates the answer key of
+// the persisted questions from the question pool, recalculates the affected
+// sessions and prints how the leaderboard changed. It returns the exit code.
+func runRegrade(args []string) int {
+	fs := flag.NewFlagSet("regrade", flag.ExitOnError)
+	registerQuestionPoolFlags(fs)
+	registerDatabaseFlags(fs)
+	fs.Parse(args)
+
+	db, err := openDatabase(false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid settings: %s\n", err.Error())
+		return 1
+	}
+
+	path, err := questionPoolPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid settings: %s\n", err.Error())
+		return 1
+	}
+
+	pool, err := models.NewQuestionPoolFromPath(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid question pool: %s\n", err.Error())
+		return 1
+	}
+
+	result, err := models.Regrade(db, pool)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Regrading failed (nothing was changed): %s\n", err.Error())
+		return 1
+	}
+
+	fmt.Printf("Updated %d question(s) in %d session(s)\n", result.UpdatedQuestions, result.UpdatedSessions)
+	printLeaderboardDiff(result.Before, result.After)
+
+	return 0
+}
+
+// printLeaderboardDiff prints the new leaderboard along with the previous
+// rank and score of every session that changed.
+func printLeaderboardDiff(before, after []models.Session) {
+	type position struct {
+		rank  int
+		score string
+	}
+	previous := map[uint]position{}
+	for _, s := range before {
+		previous[s.ID] = position{rank: s.Rank, score: s.ScoreLabel()}
+	}
+
+	fmt.Println()
+	fmt.Println("Leaderboard:")
+	for _, s := range after {
+		line := fmt.Sprintf("%3d. %-30s %-30s %8s", s.Rank, s.Nickname, s.Email, s.ScoreLabel())
+		if p, found := previous[s.ID]; !found {
+			line += " (new)"
+		} else if p.rank != s.Rank || p.score != s.ScoreLabel() {
+			line += fmt.Sprintf(" (was %d. with %s)", p.rank, p.score)
+		}
+		fmt.Println(line)
+	}
+}
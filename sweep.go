@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jimmykarily/quizmaker/internal/models"
+)
+
+// runSweep implements the "sweep" subcommand. It does what the server does
+// periodically (see models.SweepSessions) once, e.g. to run it from cron.
+// It returns the exit code.
+func runSweep(args []string) int {
+	fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+	registerDatabaseFlags(fs)
+	registerSweepFlags(fs)
+	fs.Parse(args)
+
+	db, err := openDatabase(false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid settings: %s\n", err.Error())
+		return 1
+	}
+
+	result, err := models.SweepSessions(db, abandonAfter, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Sweeping failed: %s\n", err.Error())
+		return 1
+	}
+
+	fmt.Printf("Expired %d question(s) and updated %d session(s)\n", result.ExpiredQuestions, len(result.UpdatedSessions))
+	for _, s := range result.UpdatedSessions {
+		status := "in progress"
+		if s.Complete {
+			status = "complete"
+		}
+		fmt.Printf("  %-30s %-30s %8s (%s)\n", s.Nickname, s.Email, s.ScoreLabel(), status)
+	}
+
+	return 0
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jimmykarily/quizmaker/internal/models"
+)
+
+// runValidate implements the "validate" subcommand. It reports every problem
+// in the question pool file and whether the configured quiz can be built.
+// It returns the exit code.
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	registerQuestionPoolFlags(fs)
+	fs.Parse(args)
+
+	if questionPoolFlag == "" {
+		fmt.Fprintln(os.Stderr, "Usage: quizmaker validate -question-pool <file, directory or glob>")
+		return 1
+	}
+
+	report, err := models.ValidateQuestionPoolPath(questionPoolFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid question pool: %s\n", err.Error())
+		return 1
+	}
+
+	for _, p := range report.Problems {
+		fmt.Printf("%s:%d: %s", p.File, p.Line, p.Message)
+		if p.Question != "" {
+			fmt.Printf(" (question: %q)", p.Question)
+		}
+		fmt.Println()
+	}
+	if len(report.Problems) == 0 {
+		fmt.Println("No problems found")
+	} else {
+		fmt.Printf("%d problem(s) found\n", len(report.Problems))
+	}
+
+	pool, err := models.NewQuestionPoolFromPath(questionPoolFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid question pool: %s\n", err.Error())
+		return 1
+	}
+	opts := models.QuizOptionsFor(pool, quizOverrides)
+
+	fmt.Println()
+	fmt.Println("Valid questions per difficulty:")
+	for _, d := range report.Difficulties() {
+		marker := " "
+		if d >= opts.MinDifficulty && d <= opts.MaxDifficulty {
+			marker = "*"
+		}
+		fmt.Printf("%s %2d: %3d\n", marker, d, report.ValidByDifficulty[d])
+	}
+	fmt.Printf("(* = within the quiz difficulty range %d-%d)\n", opts.MinDifficulty, opts.MaxDifficulty)
+
+	fmt.Println()
+	if err := opts.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "The quiz can't be built: %s\n", err.Error())
+		return 1
+	}
+	fmt.Printf("The quiz can be built (%d questions)\n", opts.TotalQuestions)
+
+	if len(report.Problems) > 0 {
+		return 1
+	}
+
+	return 0
+}